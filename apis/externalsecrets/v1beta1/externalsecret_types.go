@@ -170,10 +170,20 @@ type ExternalSecretDataRemoteRef struct {
 	// Policy for fetching tags/labels from provider secrets, possible options are Fetch, None. Defaults to None
 	MetadataPolicy ExternalSecretMetadataPolicy `json:"metadataPolicy,omitempty"`
 
+	// +optional
+	// Used to select a single secret by labels if the key is empty.
+	// Only supported by the Kubernetes provider, other providers ignore it.
+	LabelSelector string `json:"labelSelector,omitempty"`
+
 	// +optional
 	// Used to select a specific property of the Provider value (if a map), if supported
 	Property string `json:"property,omitempty"`
 
+	// +optional
+	// A regular expression the fetched value must match, e.g. to ensure a token has the expected prefix.
+	// Only supported by the Kubernetes provider, other providers ignore it.
+	ValuePattern string `json:"valuePattern,omitempty"`
+
 	// +optional
 	// Used to select a specific version of the Provider value, if supported
 	Version string `json:"version,omitempty"`
@@ -225,6 +235,31 @@ type ExternalSecretFind struct {
 	// Used to define a conversion Strategy
 	// +kubebuilder:default="Default"
 	ConversionStrategy ExternalSecretConversionStrategy `json:"conversionStrategy,omitempty"`
+
+	// +optional
+	// Used to strip a prefix from the names of the found secrets.
+	// Only supported by the Kubernetes provider, other providers ignore it.
+	StripPrefix string `json:"stripPrefix,omitempty"`
+
+	// +optional
+	// Used to strip a suffix from the names of the found secrets.
+	// Only supported by the Kubernetes provider, other providers ignore it.
+	StripSuffix string `json:"stripSuffix,omitempty"`
+
+	// +optional
+	// Used to return only keys modified after the given time.
+	// Only supported by the Kubernetes provider, other providers ignore it.
+	ModifiedSince *metav1.Time `json:"modifiedSince,omitempty"`
+
+	// +optional
+	// Used to stop after the given number of matching secrets. Empty or 0 means no limit.
+	// Only supported by the Kubernetes provider, other providers ignore it.
+	MaxResults int `json:"maxResults,omitempty"`
+
+	// +optional
+	// Used to return secrets without data as empty objects instead of skipping them.
+	// Only supported by the Kubernetes provider, other providers ignore it.
+	IncludeEmpty bool `json:"includeEmpty,omitempty"`
 }
 
 type FindName struct {
@@ -16,6 +16,7 @@ package v1beta1
 
 import (
 	"context"
+	"time"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -58,6 +59,19 @@ type Provider interface {
 // +k8s:deepcopy-gen:interfaces=nil
 // +k8s:deepcopy-gen=nil
 
+// RefreshFloorProvider is implemented by providers that require
+// a minimum refresh interval for a store.
+type RefreshFloorProvider interface {
+	// MinRefreshInterval returns the shortest refresh interval the controller
+	// must use for the store, or 0 if there is no limit.
+	MinRefreshInterval(store GenericStore) time.Duration
+}
+
+// +kubebuilder:object:root=false
+// +kubebuilder:object:generate:false
+// +k8s:deepcopy-gen:interfaces=nil
+// +k8s:deepcopy-gen=nil
+
 // SecretsClient provides access to secrets.
 type SecretsClient interface {
 	// GetSecret returns a single secret from the provider
@@ -15,6 +15,8 @@ limitations under the License.
 package v1beta1
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
 	esmeta "github.com/external-secrets/external-secrets/apis/meta/v1"
 )
 
@@ -25,6 +27,13 @@ type KubernetesServer struct {
 	// +optional
 	URL string `json:"url,omitempty"`
 
+	// URLs lists several addresses of the same Kubernetes server, e.g. of a
+	// highly available control plane. They are health checked in order and the
+	// first healthy one is used. The last healthy address is tried first next time.
+	// Takes precedence over URL.
+	// +optional
+	URLs []string `json:"urls,omitempty"`
+
 	// CABundle is a base64-encoded CA certificate
 	// +optional
 	CABundle []byte `json:"caBundle,omitempty"`
@@ -32,6 +41,23 @@ type KubernetesServer struct {
 	// see: https://external-secrets.io/v0.4.1/spec/#external-secrets.io/v1alpha1.CAProvider
 	// +optional
 	CAProvider *CAProvider `json:"caProvider,omitempty"`
+
+	// TLSServerName is used to verify the hostname of the server certificate
+	// and for SNI, e.g. when the server URL is an IP address.
+	// +optional
+	TLSServerName string `json:"tlsServerName,omitempty"`
+
+	// CipherSuites restricts the TLS 1.2 cipher suites used to connect,
+	// e.g. `TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384`. TLS 1.3 cipher suites
+	// are not configurable. Defaults to the Go defaults.
+	// +optional
+	CipherSuites []string `json:"cipherSuites,omitempty"`
+
+	// ProbeCABundle connects to the server when the store is validated
+	// and verifies its certificate with the CABundle. Off by default to
+	// avoid network calls during admission.
+	// +optional
+	ProbeCABundle bool `json:"probeCABundle,omitempty"`
 }
 
 // Configures a store to sync secrets with a Kubernetes instance.
@@ -46,8 +72,225 @@ type KubernetesProvider struct {
 	// +kubebuilder:default= default
 	// +optional
 	RemoteNamespace string `json:"remoteNamespace"`
+
+	// Trim removes trailing whitespace or newlines from the returned values.
+	// Binary values are never altered. Defaults to None.
+	// +optional
+	Trim KubernetesTrimStrategy `json:"trim,omitempty"`
+
+	// ValueTemplate is a Go template applied to every returned value.
+	// The value is available as `.Value`.
+	// +optional
+	ValueTemplate string `json:"valueTemplate,omitempty"`
+
+	// ExcludeKeys is a regular expression, matching keys are removed
+	// from the returned secret data.
+	// +optional
+	ExcludeKeys string `json:"excludeKeys,omitempty"`
+
+	// KeyPropertySeparator splits a remoteRef key into secret name and
+	// property if no property is set, e.g. `/` for `name/property`.
+	// +optional
+	KeyPropertySeparator string `json:"keyPropertySeparator,omitempty"`
+
+	// Dereference follows values of the form `secretRef: namespace/name/key`
+	// to the referenced value. Only one level of references is followed.
+	// +optional
+	Dereference bool `json:"dereference,omitempty"`
+
+	// AllowedKeys is a regular expression, only matching data keys can be read.
+	// +optional
+	AllowedKeys string `json:"allowedKeys,omitempty"`
+
+	// DeniedKeys is a regular expression, matching data keys can not be read.
+	// +optional
+	DeniedKeys string `json:"deniedKeys,omitempty"`
+
+	// EnvCompatibleKeys turns the keys of a whole secret into valid environment
+	// variable names: they are uppercased and invalid characters are replaced with `_`.
+	// +optional
+	EnvCompatibleKeys bool `json:"envCompatibleKeys,omitempty"`
+
+	// EmptyResultPolicy defines what happens when a find matches no secrets.
+	// Defaults to Empty.
+	// +optional
+	EmptyResultPolicy KubernetesEmptyResultPolicy `json:"emptyResultPolicy,omitempty"`
+
+	// ExpectSecretType fails reading a secret whose type differs,
+	// e.g. `kubernetes.io/tls`.
+	// +optional
+	ExpectSecretType string `json:"expectSecretType,omitempty"`
+
+	// CaseInsensitiveKeys matches properties against data keys ignoring case.
+	// Reading fails if two keys differ only by case.
+	// +optional
+	CaseInsensitiveKeys bool `json:"caseInsensitiveKeys,omitempty"`
+
+	// MaxKeys fails reading a whole secret with more keys.
+	// Empty or 0 means no limit.
+	// +optional
+	MaxKeys int `json:"maxKeys,omitempty"`
+
+	// SkipTerminating treats secrets that are being deleted as missing.
+	// +optional
+	SkipTerminating bool `json:"skipTerminating,omitempty"`
+
+	// RenderFormat defines how a whole secret is rendered if no property is set.
+	// Defaults to JSON.
+	// +optional
+	RenderFormat KubernetesRenderFormat `json:"renderFormat,omitempty"`
+
+	// CheckAccessOnRead verifies with a SelfSubjectAccessReview that the
+	// store identity may `get` a secret every time it is read.
+	// +optional
+	CheckAccessOnRead bool `json:"checkAccessOnRead,omitempty"`
+
+	// CoalesceReads shares one API call between concurrent reads
+	// of the same secret.
+	// +optional
+	CoalesceReads bool `json:"coalesceReads,omitempty"`
+
+	// CoerceToString turns numbers and booleans in the result of a JSON
+	// property path into strings, e.g. `[8080]` becomes `["8080"]`.
+	// +optional
+	CoerceToString bool `json:"coerceToString,omitempty"`
+
+	// VerifyHash compares every returned data key against the hex encoded
+	// sha256 in the annotation `hash.external-secrets.io/<key>` of the secret.
+	// Reading fails if the annotation is missing or does not match.
+	// +optional
+	VerifyHash bool `json:"verifyHash,omitempty"`
+
+	// Encode encodes every returned value after it was trimmed and templated.
+	// Defaults to None.
+	// +optional
+	Encode KubernetesEncoding `json:"encode,omitempty"`
+
+	// SanitizeKeys rewrites keys of a whole secret that can not be used in a
+	// volume mount instead of failing, e.g. `..data` or keys containing `/`.
+	// +optional
+	SanitizeKeys bool `json:"sanitizeKeys,omitempty"`
+
+	// MinRefreshInterval is the shortest refresh interval the controller uses
+	// for ExternalSecrets of this store, e.g. `5m` for a remote cluster that
+	// can not handle frequent polling. Shorter refresh intervals are raised to it.
+	// +optional
+	MinRefreshInterval *metav1.Duration `json:"minRefreshInterval,omitempty"`
+
+	// SATokenShortcut returns the `token` value of secrets of type
+	// `kubernetes.io/service-account-token` when no property is set,
+	// instead of the whole secret as JSON.
+	// +optional
+	SATokenShortcut bool `json:"saTokenShortcut,omitempty"`
+
+	// MaxAuthBackoff limits the backoff between attempts to authenticate
+	// after consecutive failures. The backoff starts at one second and doubles
+	// with every failure. Defaults to 5m, `0s` disables the backoff.
+	// +optional
+	MaxAuthBackoff *metav1.Duration `json:"maxAuthBackoff,omitempty"`
+
+	// ValueEncoding overrides the detection of binary values, which are
+	// neither trimmed nor templated and tagged as binary in YAML. `Latin1`
+	// values are converted to UTF-8 before they are marshaled.
+	// Defaults to `Auto`.
+	// +optional
+	ValueEncoding KubernetesValueEncoding `json:"valueEncoding,omitempty"`
+
+	// DefaultProperty is used by `data` refs without a property,
+	// e.g. `value` if all secrets store their payload under that key.
+	// +optional
+	DefaultProperty string `json:"defaultProperty,omitempty"`
+
+	// RequireLabel is a label selector, e.g. `eso.io/export=true`.
+	// Secrets that do not match it are treated as not found,
+	// even if they are referenced by name.
+	// +optional
+	RequireLabel string `json:"requireLabel,omitempty"`
+
+	// ListMetadataOnly makes `find` list only the metadata of the secrets
+	// and read the matching secrets one by one. It reduces the transferred
+	// data if few of many secrets match.
+	// +optional
+	ListMetadataOnly bool `json:"listMetadataOnly,omitempty"`
+
+	// FailureThreshold is the number of consecutive failed validations
+	// before the store is marked as not ready, so a single transient error
+	// does not flip it. A successful validation resets the count.
+	// Empty, 0 or 1 marks the store as not ready on the first failure.
+	// +optional
+	FailureThreshold int `json:"failureThreshold,omitempty"`
 }
 
+// KubernetesTrimStrategy defines how the returned values are trimmed.
+// +kubebuilder:validation:Enum=None;Whitespace;Newline
+type KubernetesTrimStrategy string
+
+const (
+	// KubernetesTrimNone returns the values as they are.
+	KubernetesTrimNone KubernetesTrimStrategy = "None"
+	// KubernetesTrimWhitespace removes all trailing whitespace.
+	KubernetesTrimWhitespace KubernetesTrimStrategy = "Whitespace"
+	// KubernetesTrimNewline removes trailing newlines only.
+	KubernetesTrimNewline KubernetesTrimStrategy = "Newline"
+)
+
+// KubernetesEmptyResultPolicy defines how a find without matches is handled.
+// +kubebuilder:validation:Enum=Empty;Error
+type KubernetesEmptyResultPolicy string
+
+const (
+	// KubernetesEmptyResultEmpty returns an empty result.
+	KubernetesEmptyResultEmpty KubernetesEmptyResultPolicy = "Empty"
+	// KubernetesEmptyResultError fails when no secrets match.
+	KubernetesEmptyResultError KubernetesEmptyResultPolicy = "Error"
+)
+
+// KubernetesRenderFormat defines the format of a whole secret.
+// +kubebuilder:validation:Enum=JSON;YAML
+type KubernetesRenderFormat string
+
+const (
+	// KubernetesRenderJSON renders the secret as JSON object.
+	KubernetesRenderJSON KubernetesRenderFormat = "JSON"
+	// KubernetesRenderYAML renders the secret as YAML mapping with sorted keys.
+	// Binary values are base64 encoded and tagged with `!!binary`.
+	KubernetesRenderYAML KubernetesRenderFormat = "YAML"
+)
+
+// KubernetesEncoding defines how the returned values are encoded.
+// +kubebuilder:validation:Enum=None;Base64;Base64URL;Base64URLNoPad;Gzip;Hex
+type KubernetesEncoding string
+
+const (
+	// KubernetesEncodingNone returns the values as they are.
+	KubernetesEncodingNone KubernetesEncoding = "None"
+	// KubernetesEncodingBase64 uses standard base64 with padding.
+	KubernetesEncodingBase64 KubernetesEncoding = "Base64"
+	// KubernetesEncodingBase64URL uses URL-safe base64 with padding.
+	KubernetesEncodingBase64URL KubernetesEncoding = "Base64URL"
+	// KubernetesEncodingBase64URLNoPad uses URL-safe base64 without padding, e.g. for JWTs.
+	KubernetesEncodingBase64URLNoPad KubernetesEncoding = "Base64URLNoPad"
+	// KubernetesEncodingGzip compresses the values with gzip.
+	KubernetesEncodingGzip KubernetesEncoding = "Gzip"
+	// KubernetesEncodingHex uses lowercase hex.
+	KubernetesEncodingHex KubernetesEncoding = "Hex"
+)
+
+// KubernetesValueEncoding defines how the values of the remote secrets are interpreted.
+// +kubebuilder:validation:Enum=Auto;UTF8;Latin1;Binary
+type KubernetesValueEncoding string
+
+const (
+	// KubernetesValueEncodingAuto detects binary values.
+	KubernetesValueEncodingAuto KubernetesValueEncoding = "Auto"
+	// KubernetesValueEncodingUTF8 treats all values as UTF-8 text.
+	KubernetesValueEncodingUTF8 KubernetesValueEncoding = "UTF8"
+	// KubernetesValueEncodingLatin1 treats all values as latin1 (ISO-8859-1) text.
+	KubernetesValueEncodingLatin1 KubernetesValueEncoding = "Latin1"
+	// KubernetesValueEncodingBinary treats all values as binary.
+	KubernetesValueEncodingBinary KubernetesValueEncoding = "Binary"
+)
+
 // +kubebuilder:validation:MinProperties=1
 // +kubebuilder:validation:MaxProperties=1
 type KubernetesAuth struct {
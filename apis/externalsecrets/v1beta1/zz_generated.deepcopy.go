@@ -625,6 +625,10 @@ func (in *ExternalSecretFind) DeepCopyInto(out *ExternalSecretFind) {
 			(*out)[key] = val
 		}
 	}
+	if in.ModifiedSince != nil {
+		in, out := &in.ModifiedSince, &out.ModifiedSince
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalSecretFind.
@@ -1119,6 +1123,16 @@ func (in *KubernetesProvider) DeepCopyInto(out *KubernetesProvider) {
 	*out = *in
 	in.Server.DeepCopyInto(&out.Server)
 	in.Auth.DeepCopyInto(&out.Auth)
+	if in.MinRefreshInterval != nil {
+		in, out := &in.MinRefreshInterval, &out.MinRefreshInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MaxAuthBackoff != nil {
+		in, out := &in.MaxAuthBackoff, &out.MaxAuthBackoff
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubernetesProvider.
@@ -1134,6 +1148,11 @@ func (in *KubernetesProvider) DeepCopy() *KubernetesProvider {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KubernetesServer) DeepCopyInto(out *KubernetesServer) {
 	*out = *in
+	if in.URLs != nil {
+		in, out := &in.URLs, &out.URLs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.CABundle != nil {
 		in, out := &in.CABundle, &out.CABundle
 		*out = make([]byte, len(*in))
@@ -1144,6 +1163,11 @@ func (in *KubernetesServer) DeepCopyInto(out *KubernetesServer) {
 		*out = new(CAProvider)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.CipherSuites != nil {
+		in, out := &in.CipherSuites, &out.CipherSuites
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubernetesServer.
@@ -39,6 +39,7 @@ import (
 	"github.com/external-secrets/external-secrets/pkg/controllers/externalsecret"
 	"github.com/external-secrets/external-secrets/pkg/controllers/secretstore"
 	awsauth "github.com/external-secrets/external-secrets/pkg/provider/aws/auth"
+	k8sprovider "github.com/external-secrets/external-secrets/pkg/provider/kubernetes"
 )
 
 var (
@@ -164,6 +165,10 @@ var rootCmd = &cobra.Command{
 		if enableAWSSession {
 			awsauth.EnableCache = true
 		}
+		providerRecorder := mgr.GetEventRecorderFor("kubernetes-provider")
+		k8sprovider.ErrorHook = func(store esv1beta1.GenericStore, reason, message string) {
+			providerRecorder.Event(store, v1.EventTypeWarning, reason, message)
+		}
 		setupLog.Info("starting manager")
 		if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 			setupLog.Error(err, "problem running manager")
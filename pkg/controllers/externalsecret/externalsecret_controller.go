@@ -165,10 +165,8 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		return ctrl.Result{RequeueAfter: requeueAfter}, nil
 	}
 
-	refreshInt := r.RequeueInterval
-	if externalSecret.Spec.RefreshInterval != nil {
-		refreshInt = externalSecret.Spec.RefreshInterval.Duration
-	}
+	refreshFloor := getRefreshFloor(storeProvider, store)
+	refreshInt := r.getRefreshInterval(externalSecret, refreshFloor)
 
 	// Target Secret Name should default to the ExternalSecret name if not explicitly specified
 	secretName := externalSecret.Spec.Target.Name
@@ -190,7 +188,8 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 	// 1. resource generation hasn't changed
 	// 2. refresh interval is 0
 	// 3. if we're still within refresh-interval
-	if !shouldRefresh(externalSecret) && isSecretValid(existingSecret) {
+	// 4. if we're still within the minimum refresh interval of the store
+	if (!shouldRefresh(externalSecret) || withinRefreshFloor(externalSecret, refreshFloor)) && isSecretValid(existingSecret) {
 		log.V(1).Info("skipping refresh", "rv", getResourceVersion(externalSecret))
 		return ctrl.Result{RequeueAfter: refreshInt}, nil
 	}
@@ -453,6 +452,37 @@ func shouldRefresh(es esv1beta1.ExternalSecret) bool {
 	return !es.Status.RefreshTime.Add(es.Spec.RefreshInterval.Duration).After(time.Now())
 }
 
+// getRefreshInterval returns the refresh interval of the ExternalSecret,
+// raised to the minimum refresh interval of the store.
+func (r *Reconciler) getRefreshInterval(es esv1beta1.ExternalSecret, floor time.Duration) time.Duration {
+	refreshInt := r.RequeueInterval
+	if es.Spec.RefreshInterval != nil {
+		refreshInt = es.Spec.RefreshInterval.Duration
+	}
+	if refreshInt > 0 && refreshInt < floor {
+		refreshInt = floor
+	}
+	return refreshInt
+}
+
+// getRefreshFloor returns the minimum refresh interval the provider requires for the store.
+func getRefreshFloor(provider esv1beta1.Provider, store esv1beta1.GenericStore) time.Duration {
+	p, ok := provider.(esv1beta1.RefreshFloorProvider)
+	if !ok {
+		return 0
+	}
+	return p.MinRefreshInterval(store)
+}
+
+// withinRefreshFloor reports whether the last refresh was less than
+// the minimum refresh interval ago. Changes to the resource always refresh.
+func withinRefreshFloor(es esv1beta1.ExternalSecret, floor time.Duration) bool {
+	if floor <= 0 || es.Status.SyncedResourceVersion != getResourceVersion(es) || es.Status.RefreshTime.IsZero() {
+		return false
+	}
+	return es.Status.RefreshTime.Add(floor).After(time.Now())
+}
+
 func shouldReconcile(es esv1beta1.ExternalSecret) bool {
 	if es.Spec.Target.Immutable && hasSyncedCondition(es) {
 		return false
@@ -1421,6 +1421,28 @@ var _ = Describe("ExternalSecret refresh logic", func() {
 			Expect(shouldRefresh(es)).To(BeTrue())
 		})
 
+		It("should not refresh within the minimum refresh interval of the store", func() {
+			es := esv1beta1.ExternalSecret{
+				ObjectMeta: metav1.ObjectMeta{
+					Generation: 1,
+				},
+				Spec: esv1beta1.ExternalSecretSpec{
+					RefreshInterval: &metav1.Duration{Duration: time.Second},
+				},
+				Status: esv1beta1.ExternalSecretStatus{
+					RefreshTime: metav1.NewTime(metav1.Now().Add(-time.Second * 5)),
+				},
+			}
+			es.Status.SyncedResourceVersion = getResourceVersion(es)
+			Expect(withinRefreshFloor(es, time.Minute)).To(BeTrue())
+			Expect(withinRefreshFloor(es, time.Second)).To(BeFalse())
+			Expect(withinRefreshFloor(es, 0)).To(BeFalse())
+
+			// changes to the resource are not delayed
+			es.ObjectMeta.Generation = 2
+			Expect(withinRefreshFloor(es, time.Minute)).To(BeFalse())
+		})
+
 	})
 	Context("objectmeta hash", func() {
 		It("should produce different hashes for different k/v pairs", func() {
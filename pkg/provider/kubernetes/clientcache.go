@@ -0,0 +1,282 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// maxCachedClientSets bounds the cache, it is reset when full
+// so clientsets of rotated credentials do not pile up.
+const maxCachedClientSets = 100
+
+const (
+	authBackoffBase       = time.Second
+	defaultMaxAuthBackoff = 5 * time.Minute
+	// authBackoffJitter adds up to half of the backoff, so
+	// stores that failed together do not retry together.
+	authBackoffJitter = 0.5
+
+	healthCheckPath    = "/readyz"
+	healthCheckTimeout = 5 * time.Second
+	// healthCheckTTL is how long a healthy endpoint is used
+	// without checking it again.
+	healthCheckTTL = 30 * time.Second
+)
+
+var (
+	clientSetsMu sync.Mutex
+	clientSets   = map[string]kubernetes.Interface{}
+
+	authBackoffsMu sync.Mutex
+	authBackoffs   = map[string]*authBackoff{}
+
+	lastHealthyMu sync.Mutex
+	lastHealthy   = map[string]healthyEndpoint{}
+)
+
+// healthyEndpoint is the last endpoint of a client that passed the health check.
+type healthyEndpoint struct {
+	url       string
+	checkedAt time.Time
+}
+
+// authBackoff tracks the consecutive authentication failures of a store.
+type authBackoff struct {
+	failures int
+	retryAt  time.Time
+	lastErr  error
+}
+
+// clientSetFor returns a clientset for the config, restricted to the given
+// TLS cipher suites if any. Stores that connect to the same server with the
+// same credentials share a clientset and its transport.
+func clientSetFor(cfg *rest.Config, cipherSuites []uint16) (kubernetes.Interface, error) {
+	key := configFingerprint(cfg, cipherSuites)
+	clientSetsMu.Lock()
+	defer clientSetsMu.Unlock()
+	if cs, ok := clientSets[key]; ok {
+		return cs, nil
+	}
+	if len(cipherSuites) > 0 {
+		var err error
+		cfg, err = withCipherSuites(cfg, cipherSuites)
+		if err != nil {
+			return nil, err
+		}
+	}
+	cs, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(clientSets) >= maxCachedClientSets {
+		clientSets = map[string]kubernetes.Interface{}
+	}
+	clientSets[key] = cs
+	return cs, nil
+}
+
+// clientSetForEndpoints returns a clientset for server.url or, if server.urls
+// is set, for the first of them that passes a health check. The last healthy
+// endpoint of the client is tried first and is not checked again within
+// healthCheckTTL.
+func clientSetForEndpoints(ctx context.Context, client *BaseClient, key string, cipherSuites []uint16, now time.Time) (kubernetes.Interface, error) {
+	urls := client.store.Server.URLs
+	if len(urls) == 0 {
+		return clientSetFor(client.newRestConfig(), cipherSuites)
+	}
+	failures := make([]string, 0, len(urls))
+	for _, url := range endpointOrder(key, urls) {
+		cfg := client.newRestConfig()
+		cfg.Host = url
+		cs, err := clientSetFor(cfg, cipherSuites)
+		if err == nil && recentlyHealthy(key, url, now) {
+			return cs, nil
+		}
+		if err == nil {
+			err = healthCheck(ctx, cs)
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", url, err))
+			forgetHealthy(key, url)
+			continue
+		}
+		lastHealthyMu.Lock()
+		lastHealthy[key] = healthyEndpoint{url: url, checkedAt: now}
+		lastHealthyMu.Unlock()
+		return cs, nil
+	}
+	return nil, fmt.Errorf("no healthy server: %s", strings.Join(failures, "; "))
+}
+
+// recentlyHealthy reports whether url is the last healthy endpoint
+// of the client and passed its health check within healthCheckTTL.
+func recentlyHealthy(key, url string, now time.Time) bool {
+	lastHealthyMu.Lock()
+	defer lastHealthyMu.Unlock()
+	last, ok := lastHealthy[key]
+	return ok && last.url == url && now.Before(last.checkedAt.Add(healthCheckTTL))
+}
+
+// forgetHealthy drops url if it is the last healthy endpoint of the client.
+func forgetHealthy(key, url string) {
+	lastHealthyMu.Lock()
+	defer lastHealthyMu.Unlock()
+	if last, ok := lastHealthy[key]; ok && last.url == url {
+		delete(lastHealthy, key)
+	}
+}
+
+// endpointOrder moves the last healthy endpoint of the client to the front.
+func endpointOrder(key string, urls []string) []string {
+	lastHealthyMu.Lock()
+	last := lastHealthy[key].url
+	lastHealthyMu.Unlock()
+	ordered := make([]string, 0, len(urls))
+	for _, url := range urls {
+		if url == last {
+			ordered = append([]string{url}, ordered...)
+			continue
+		}
+		ordered = append(ordered, url)
+	}
+	return ordered
+}
+
+// healthCheck verifies that the API server is ready to serve requests.
+func healthCheck(ctx context.Context, cs kubernetes.Interface) error {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	_, err := cs.Discovery().RESTClient().Get().AbsPath(healthCheckPath).DoRaw(ctx)
+	return err
+}
+
+// configFingerprint identifies the endpoint and credentials of a config.
+func configFingerprint(cfg *rest.Config, cipherSuites []uint16) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%v:", cipherSuites)
+	for _, v := range [][]byte{
+		[]byte(cfg.Host),
+		[]byte(cfg.TLSClientConfig.ServerName),
+		cfg.TLSClientConfig.CAData,
+		cfg.TLSClientConfig.CertData,
+		cfg.TLSClientConfig.KeyData,
+		[]byte(cfg.BearerToken),
+		[]byte(fmt.Sprintf("%p", cfg.Dial)),
+	} {
+		// the length prefix keeps the concatenation unambiguous
+		fmt.Fprintf(h, "%d:", len(v))
+		h.Write(v)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cipherSuiteIDs maps cipher suite names to their IDs.
+// Only cipher suites without known security issues are accepted.
+func cipherSuiteIDs(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %s", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// withCipherSuites moves the TLS settings of the config into a custom
+// transport, because rest.Config has no option for cipher suites.
+func withCipherSuites(cfg *rest.Config, cipherSuites []uint16) (*rest.Config, error) {
+	tlsConfig, err := rest.TLSConfigFor(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	tlsConfig.CipherSuites = cipherSuites
+	out := rest.CopyConfig(cfg)
+	out.Transport = utilnet.SetTransportDefaults(&http.Transport{
+		TLSClientConfig: tlsConfig,
+		DialContext:     cfg.Dial,
+	})
+	out.TLSClientConfig = rest.TLSClientConfig{}
+	out.Dial = nil
+	return out, nil
+}
+
+// checkAuthBackoff fails while the store is backing off after
+// authentication failures, without contacting the remote.
+func checkAuthBackoff(key string, now time.Time) error {
+	authBackoffsMu.Lock()
+	defer authBackoffsMu.Unlock()
+	b, ok := authBackoffs[key]
+	if !ok || !now.Before(b.retryAt) {
+		return nil
+	}
+	return fmt.Errorf("backing off after %d authentication failures, retrying in %s: %w", b.failures, b.retryAt.Sub(now).Round(time.Second), b.lastErr)
+}
+
+// recordAuthResult extends the backoff of the store on a failure
+// and resets it on success.
+func recordAuthResult(key string, now time.Time, maxBackoff time.Duration, err error) {
+	authBackoffsMu.Lock()
+	defer authBackoffsMu.Unlock()
+	if err == nil || maxBackoff <= 0 {
+		delete(authBackoffs, key)
+		return
+	}
+	b, ok := authBackoffs[key]
+	if !ok {
+		b = &authBackoff{}
+		authBackoffs[key] = b
+	}
+	b.failures++
+	b.lastErr = err
+	b.retryAt = now.Add(wait.Jitter(authBackoffDelay(b.failures, maxBackoff), authBackoffJitter))
+}
+
+// authBackoffDelay doubles the delay with every failure up to maxBackoff.
+func authBackoffDelay(failures int, maxBackoff time.Duration) time.Duration {
+	delay := authBackoffBase
+	for i := 1; i < failures && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,197 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	esv1beta1 "github.com/external-secrets/external-secrets/apis/externalsecrets/v1beta1"
+)
+
+func TestClientSetFor(t *testing.T) {
+	newBaseClient := func(token string) *BaseClient {
+		return &BaseClient{
+			store: &esv1beta1.KubernetesProvider{
+				Server: esv1beta1.KubernetesServer{
+					URL: "https://my.remote.cluster",
+				},
+			},
+			CA:          []byte(testCertificate),
+			BearerToken: []byte(token),
+		}
+	}
+
+	first, err := clientSetFor(newBaseClient("token-a").newRestConfig(), nil)
+	assert.NoError(t, err)
+	second, err := clientSetFor(newBaseClient("token-a").newRestConfig(), nil)
+	assert.NoError(t, err)
+	assert.Same(t, first, second, "identical configs should share a clientset")
+
+	other, err := clientSetFor(newBaseClient("token-b").newRestConfig(), nil)
+	assert.NoError(t, err)
+	assert.NotSame(t, first, other, "different credentials must not share a clientset")
+}
+
+func TestCipherSuiteIDs(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   []string
+		want    []uint16
+		wantErr string
+	}{
+		{
+			name: "default",
+		},
+		{
+			name:  "valid list",
+			names: []string{"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384", "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+			want:  []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384, tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+		},
+		{
+			name:    "unknown cipher suite",
+			names:   []string{"TLS_FOO"},
+			wantErr: "unknown or insecure cipher suite TLS_FOO",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cipherSuiteIDs(tt.names)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWithCipherSuites(t *testing.T) {
+	client := &BaseClient{
+		store: &esv1beta1.KubernetesProvider{
+			Server: esv1beta1.KubernetesServer{
+				URL:           "https://my.remote.cluster",
+				TLSServerName: "remote",
+			},
+		},
+		CA:          []byte(testCertificate),
+		BearerToken: []byte("token"),
+	}
+	cfg, err := withCipherSuites(client.newRestConfig(), []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384})
+	assert.NoError(t, err)
+	transport, ok := cfg.Transport.(*http.Transport)
+	assert.True(t, ok)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}, transport.TLSClientConfig.CipherSuites)
+	assert.Equal(t, "remote", transport.TLSClientConfig.ServerName)
+	assert.NotNil(t, transport.TLSClientConfig.RootCAs)
+	assert.Equal(t, "token", cfg.BearerToken)
+}
+
+func TestAuthBackoff(t *testing.T) {
+	const key = "SecretStore/default/backoff"
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	errAuth := errors.New("secret foo not found")
+	defer recordAuthResult(key, now, defaultMaxAuthBackoff, nil)
+
+	assert.NoError(t, checkAuthBackoff(key, now))
+
+	// every failure doubles the backoff, the jitter adds up to half of it
+	var last time.Duration
+	for i, base := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		recordAuthResult(key, now, defaultMaxAuthBackoff, errAuth)
+		backoff := authBackoffs[key].retryAt.Sub(now)
+		assert.GreaterOrEqual(t, backoff, base, "failure %d", i+1)
+		assert.Less(t, backoff, base+base/2, "failure %d", i+1)
+		assert.Greater(t, backoff, last, "failure %d", i+1)
+		last = backoff
+
+		err := checkAuthBackoff(key, now.Add(backoff-time.Millisecond))
+		assert.ErrorIs(t, err, errAuth)
+		assert.NoError(t, checkAuthBackoff(key, now.Add(backoff)))
+	}
+
+	// a success resets the backoff
+	recordAuthResult(key, now, defaultMaxAuthBackoff, nil)
+	assert.NoError(t, checkAuthBackoff(key, now))
+	recordAuthResult(key, now, defaultMaxAuthBackoff, errAuth)
+	assert.Less(t, authBackoffs[key].retryAt.Sub(now), time.Second+time.Second/2)
+}
+
+func TestAuthBackoffDelay(t *testing.T) {
+	assert.Equal(t, time.Second, authBackoffDelay(1, time.Minute))
+	assert.Equal(t, 8*time.Second, authBackoffDelay(4, time.Minute))
+	assert.Equal(t, time.Minute, authBackoffDelay(10, time.Minute))
+	assert.Equal(t, time.Minute, authBackoffDelay(1000, time.Minute))
+}
+
+func TestClientSetForEndpoints(t *testing.T) {
+	const key = "SecretStore/default/failover/"
+	defer func() {
+		lastHealthyMu.Lock()
+		delete(lastHealthy, key)
+		lastHealthyMu.Unlock()
+	}()
+	var checks int32
+	live := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/readyz" {
+			atomic.AddInt32(&checks, 1)
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer live.Close()
+	dead := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	dead.Close()
+
+	newClient := func(urls ...string) *BaseClient {
+		return &BaseClient{
+			store: &esv1beta1.KubernetesProvider{
+				Server: esv1beta1.KubernetesServer{
+					URLs: urls,
+				},
+			},
+			CA: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: live.Certificate().Raw}),
+		}
+	}
+
+	now := time.Now()
+	cs, err := clientSetForEndpoints(context.Background(), newClient(dead.URL, live.URL), key, nil, now)
+	assert.NoError(t, err)
+	assert.NotNil(t, cs)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&checks))
+
+	// the last healthy endpoint is tried first
+	assert.Equal(t, []string{live.URL, dead.URL}, endpointOrder(key, []string{dead.URL, live.URL}))
+
+	// and is not checked again within healthCheckTTL
+	_, err = clientSetForEndpoints(context.Background(), newClient(dead.URL, live.URL), key, nil, now.Add(healthCheckTTL-time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&checks))
+	_, err = clientSetForEndpoints(context.Background(), newClient(dead.URL, live.URL), key, nil, now.Add(healthCheckTTL))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&checks))
+
+	_, err = clientSetForEndpoints(context.Background(), newClient(dead.URL), key, nil, now)
+	assert.ErrorContains(t, err, "no healthy server: "+dead.URL)
+}
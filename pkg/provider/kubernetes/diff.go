@@ -0,0 +1,76 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"sort"
+
+	esv1beta1 "github.com/external-secrets/external-secrets/apis/externalsecrets/v1beta1"
+)
+
+// SecretDiff lists the keys of a remote secret that differ from a baseline.
+// It never contains values.
+type SecretDiff struct {
+	// Added keys exist in the remote secret but not in the baseline.
+	Added []string `json:"added"`
+	// Removed keys exist in the baseline but not in the remote secret.
+	Removed []string `json:"removed"`
+	// Changed keys exist in both with different values.
+	Changed []string `json:"changed"`
+}
+
+// Empty reports whether the remote secret matches the baseline.
+func (d SecretDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffSecret compares the data of the remote secret, as returned by
+// GetSecretMap, with the baseline, e.g. to detect drift.
+func (p *ProviderKubernetes) DiffSecret(ctx context.Context, ref esv1beta1.ExternalSecretDataRemoteRef, baseline map[string][]byte) (SecretDiff, error) {
+	remote, err := p.GetSecretMap(ctx, ref)
+	if err != nil {
+		return SecretDiff{}, err
+	}
+	return diffData(baseline, remote), nil
+}
+
+// diffData returns the sorted keys that were added, removed or changed in remote.
+func diffData(baseline, remote map[string][]byte) SecretDiff {
+	diff := SecretDiff{
+		Added:   []string{},
+		Removed: []string{},
+		Changed: []string{},
+	}
+	for k, v := range remote {
+		old, ok := baseline[k]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, k)
+		case !bytes.Equal(old, v):
+			diff.Changed = append(diff.Changed, k)
+		}
+	}
+	for k := range baseline {
+		if _, ok := remote[k]; !ok {
+			diff.Removed = append(diff.Removed, k)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff
+}
@@ -0,0 +1,96 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+
+	esv1beta1 "github.com/external-secrets/external-secrets/apis/externalsecrets/v1beta1"
+)
+
+func TestDiffSecret(t *testing.T) {
+	remote := map[string][]byte{
+		"username": []byte("admin"),
+		"password": []byte("n3w"),
+		"token":    []byte("foobar"),
+	}
+	tests := []struct {
+		name     string
+		baseline map[string][]byte
+		want     SecretDiff
+	}{
+		{
+			name: "no drift",
+			baseline: map[string][]byte{
+				"username": []byte("admin"),
+				"password": []byte("n3w"),
+				"token":    []byte("foobar"),
+			},
+			want: SecretDiff{Added: []string{}, Removed: []string{}, Changed: []string{}},
+		},
+		{
+			name: "added keys",
+			baseline: map[string][]byte{
+				"username": []byte("admin"),
+				"password": []byte("n3w"),
+			},
+			want: SecretDiff{Added: []string{"token"}, Removed: []string{}, Changed: []string{}},
+		},
+		{
+			name: "removed keys",
+			baseline: map[string][]byte{
+				"username": []byte("admin"),
+				"password": []byte("n3w"),
+				"token":    []byte("foobar"),
+				"api-key":  []byte("xyz"),
+			},
+			want: SecretDiff{Added: []string{}, Removed: []string{"api-key"}, Changed: []string{}},
+		},
+		{
+			name: "changed keys",
+			baseline: map[string][]byte{
+				"username": []byte("admin"),
+				"password": []byte("old"),
+				"token":    []byte("foobar"),
+			},
+			want: SecretDiff{Added: []string{}, Removed: []string{}, Changed: []string{"password"}},
+		},
+		{
+			name:     "empty baseline",
+			baseline: nil,
+			want:     SecretDiff{Added: []string{"password", "token", "username"}, Removed: []string{}, Changed: []string{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ProviderKubernetes{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {Data: remote},
+					},
+				},
+			}
+			got, err := p.DiffSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{Key: "mysec"}, tt.baseline)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.name == "no drift", got.Empty())
+		})
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	esv1beta1 "github.com/external-secrets/external-secrets/apis/externalsecrets/v1beta1"
+)
+
+const (
+	reasonNewClientFailed = "NewClientFailed"
+	reasonGetSecretFailed = "GetSecretFailed"
+	reasonFindTruncated   = "FindResultTruncated"
+)
+
+// ErrorHookFunc receives the reason and message of a provider failure.
+type ErrorHookFunc func(store esv1beta1.GenericStore, reason, message string)
+
+// ErrorHook is called when the provider fails to construct a client,
+// to read a secret or truncates a find result at maxResults,
+// e.g. to record an Event on the store.
+// Repeated identical failures of a store and remote key are only reported once.
+var ErrorHook ErrorHookFunc
+
+var (
+	reportedErrorsMu sync.Mutex
+	// reportedErrors holds the last reported message by store, reason and remote key.
+	reportedErrors = map[string]string{}
+)
+
+// reportResult passes a failure to the ErrorHook unless it has already been
+// reported for the remote key. A success resets the reported failure of the
+// remote key only, failures of other keys of the store stay deduplicated.
+func reportResult(store esv1beta1.GenericStore, reason, remoteKey string, err error) {
+	if ErrorHook == nil || store == nil || errors.Is(err, esv1beta1.NoSecretErr) {
+		return
+	}
+	key := strings.Join([]string{store.GetObjectKind().GroupVersionKind().Kind, store.GetNamespace(), store.GetName(), reason, remoteKey}, "/")
+	reportedErrorsMu.Lock()
+	if err == nil {
+		delete(reportedErrors, key)
+		reportedErrorsMu.Unlock()
+		return
+	}
+	msg := err.Error()
+	if last, ok := reportedErrors[key]; ok && last == msg {
+		reportedErrorsMu.Unlock()
+		return
+	}
+	reportedErrors[key] = msg
+	reportedErrorsMu.Unlock()
+	ErrorHook(store, reason, msg)
+}
@@ -0,0 +1,100 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	esv1beta1 "github.com/external-secrets/external-secrets/apis/externalsecrets/v1beta1"
+)
+
+func TestErrorHook(t *testing.T) {
+	var reasons []string
+	ErrorHook = func(store esv1beta1.GenericStore, reason, message string) {
+		assert.Equal(t, "example", store.GetName())
+		reasons = append(reasons, reason)
+	}
+	defer func() { ErrorHook = nil }()
+
+	secrets := map[string]corev1.Secret{}
+	p := &ProviderKubernetes{
+		Client: fakeClient{
+			t:         t,
+			secretMap: secrets,
+		},
+		genericStore: &esv1beta1.SecretStore{
+			TypeMeta: metav1.TypeMeta{
+				Kind: esv1beta1.SecretStoreKind,
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: "default",
+			},
+		},
+	}
+	ref := esv1beta1.ExternalSecretDataRemoteRef{Key: "mysec"}
+
+	// repeated identical failures are reported once
+	_, err := p.GetSecret(context.Background(), ref)
+	assert.Error(t, err)
+	_, err = p.GetSecret(context.Background(), ref)
+	assert.Error(t, err)
+	assert.Equal(t, []string{reasonGetSecretFailed}, reasons)
+
+	// a success resets the reported failure
+	secrets["mysec"] = corev1.Secret{}
+	_, err = p.GetSecret(context.Background(), ref)
+	assert.NoError(t, err)
+	delete(secrets, "mysec")
+	_, err = p.GetSecret(context.Background(), ref)
+	assert.Error(t, err)
+	assert.Equal(t, []string{reasonGetSecretFailed, reasonGetSecretFailed}, reasons)
+
+	// a success of another secret does not reset the failure
+	secrets["other"] = corev1.Secret{}
+	_, err = p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{Key: "other"})
+	assert.NoError(t, err)
+	_, err = p.GetSecret(context.Background(), ref)
+	assert.Error(t, err)
+	assert.Equal(t, []string{reasonGetSecretFailed, reasonGetSecretFailed}, reasons)
+
+	// failures of different secrets are reported separately
+	_, err = p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{Key: "missing"})
+	assert.Error(t, err)
+	_, err = p.GetSecret(context.Background(), ref)
+	assert.Error(t, err)
+	assert.Equal(t, []string{reasonGetSecretFailed, reasonGetSecretFailed, reasonGetSecretFailed}, reasons)
+
+	// NewClient failures are reported with their own reason
+	_, err = p.NewClient(context.Background(), &esv1beta1.SecretStore{
+		TypeMeta: metav1.TypeMeta{
+			Kind: esv1beta1.SecretStoreKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+		Spec: esv1beta1.SecretStoreSpec{
+			Provider: &esv1beta1.SecretStoreProvider{},
+		},
+	}, nil, "default")
+	assert.Error(t, err)
+	assert.Equal(t, []string{reasonGetSecretFailed, reasonGetSecretFailed, reasonGetSecretFailed, reasonNewClientFailed}, reasons)
+}
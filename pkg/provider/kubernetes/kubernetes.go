@@ -15,26 +15,106 @@ limitations under the License.
 package kubernetes
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	tpl "text/template"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/tidwall/gjson"
+	"golang.org/x/sync/singleflight"
+	"gopkg.in/yaml.v3"
 	authv1 "k8s.io/api/authorization/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	labels "k8s.io/apimachinery/pkg/labels"
-	"k8s.io/client-go/kubernetes"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/rest"
+	"k8s.io/utils/clock"
+	ctrl "sigs.k8s.io/controller-runtime"
 	kclient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	esv1beta1 "github.com/external-secrets/external-secrets/apis/externalsecrets/v1beta1"
 	"github.com/external-secrets/external-secrets/pkg/find"
+	"github.com/external-secrets/external-secrets/pkg/template/v2"
 	"github.com/external-secrets/external-secrets/pkg/utils"
 )
 
+const (
+	metadataAnnotationsPrefix = "metadata.annotations."
+	metadataLabelsPrefix      = "metadata.labels."
+
+	keyCountProperty    = "keyCount"
+	byteSizeProperty    = "byteSize"
+	checksumProperty    = "checksum"
+	deduplicateProperty = "deduplicate"
+	binaryKeysProperty  = "binaryKeys"
+	manifestProperty    = "@manifest"
+	tlsProperty         = "@tls"
+
+	// caCertKey is the optional CA certificate of a TLS secret in @tls.
+	caCertKey = "ca.crt"
+
+	namespaceProperty         = "namespace"
+	deletionTimestampProperty = "deletionTimestamp"
+	finalizersProperty        = "finalizers"
+	ownerCountProperty        = "ownerCount"
+
+	managedFieldsPrefix     = "managedFields."
+	managedFieldsTimeSuffix = ".time"
+
+	listPageSize = 100
+
+	secretRefPrefix     = "secretRef:"
+	maxDereferenceDepth = 1
+
+	keysProperty     = "@keys"
+	pathsProperty    = "@paths"
+	projectionPrefix = "@project:"
+
+	hashAnnotationPrefix = "hash.external-secrets.io/"
+
+	serviceAccountKeyPrefix = "sa:"
+	serviceAccountTokenKey  = "token"
+
+	propertyFormatSeparator = "|"
+	iniFormat               = "ini"
+	tarFormat               = "tar"
+
+	// maxArchiveEntrySize bounds the size of a file extracted from an
+	// archive, it matches the maximum size of a secret.
+	maxArchiveEntrySize = 1 << 20
+)
+
+var log = ctrl.Log.WithName("provider").WithName("kubernetes")
+
+// errMaxResults stops a find once maxResults secrets were found.
+var errMaxResults = errors.New("maximum number of results reached")
+
+// errGzipSerialized rejects Gzip for secrets that are returned as JSON or YAML.
+var errGzipSerialized = errors.New("encode Gzip requires a property, compressed values can not be serialized as JSON or YAML")
+
 // https://github.com/external-secrets/external-secrets/issues/644
 var _ esv1beta1.SecretsClient = &ProviderKubernetes{}
 var _ esv1beta1.Provider = &ProviderKubernetes{}
+var _ esv1beta1.RefreshFloorProvider = &ProviderKubernetes{}
 
 type KClient interface {
 	Get(ctx context.Context, name string, opts metav1.GetOptions) (*corev1.Secret, error)
@@ -45,13 +125,43 @@ type RClient interface {
 	Create(ctx context.Context, selfSubjectRulesReview *authv1.SelfSubjectRulesReview, opts metav1.CreateOptions) (*authv1.SelfSubjectRulesReview, error)
 }
 
+type AClient interface {
+	Create(ctx context.Context, selfSubjectAccessReview *authv1.SelfSubjectAccessReview, opts metav1.CreateOptions) (*authv1.SelfSubjectAccessReview, error)
+}
+
+type SAClient interface {
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (*corev1.ServiceAccount, error)
+}
+
+type NSClient interface {
+	List(ctx context.Context, opts metav1.ListOptions) (*corev1.NamespaceList, error)
+}
+
+type MClient interface {
+	List(ctx context.Context, opts metav1.ListOptions) (*metav1.PartialObjectMetadataList, error)
+}
+
 // ProviderKubernetes is a provider for Kubernetes.
 type ProviderKubernetes struct {
-	Client       KClient
-	ReviewClient RClient
-	Namespace    string
-	store        *esv1beta1.KubernetesProvider
-	storeKind    string
+	Client               KClient
+	ReviewClient         RClient
+	AccessReviewClient   AClient
+	ServiceAccountClient SAClient
+	NamespaceClient      NSClient
+	MetadataClient       MClient
+	Namespace            string
+	store                *esv1beta1.KubernetesProvider
+	storeKind            string
+	// valueTemplate is parsed once from store.ValueTemplate.
+	valueTemplate  *tpl.Template
+	excludeKeys    *regexp.Regexp
+	allowedKeys    *regexp.Regexp
+	deniedKeys     *regexp.Regexp
+	requireLabel   labels.Selector
+	genericStore   esv1beta1.GenericStore
+	storeName      string
+	storeNamespace string
+	clock          clock.PassiveClock
 }
 
 var _ esv1beta1.SecretsClient = &ProviderKubernetes{}
@@ -75,6 +185,12 @@ func init() {
 
 // NewClient constructs a Kubernetes Provider.
 func (p *ProviderKubernetes) NewClient(ctx context.Context, store esv1beta1.GenericStore, kube kclient.Client, namespace string) (esv1beta1.SecretsClient, error) {
+	client, err := p.newClient(ctx, store, kube, namespace)
+	reportResult(store, reasonNewClientFailed, "", err)
+	return client, err
+}
+
+func (p *ProviderKubernetes) newClient(ctx context.Context, store esv1beta1.GenericStore, kube kclient.Client, namespace string) (esv1beta1.SecretsClient, error) {
 	storeSpec := store.GetSpec()
 	if storeSpec == nil || storeSpec.Provider == nil || storeSpec.Provider.Kubernetes == nil {
 		return nil, fmt.Errorf("no store type or wrong store type")
@@ -87,38 +203,153 @@ func (p *ProviderKubernetes) NewClient(ctx context.Context, store esv1beta1.Gene
 		namespace: namespace,
 		storeKind: store.GetObjectKind().GroupVersionKind().Kind,
 	}
-	p.Namespace = client.store.RemoteNamespace
-	p.store = storeSpecKubernetes
-	p.storeKind = store.GetObjectKind().GroupVersionKind().Kind
+	// every client gets its own provider, the registered provider is
+	// shared between all stores and must not hold any store state
+	c := &ProviderKubernetes{
+		Namespace:      client.store.RemoteNamespace,
+		store:          storeSpecKubernetes,
+		storeKind:      client.storeKind,
+		genericStore:   store,
+		storeName:      store.GetName(),
+		storeNamespace: store.GetNamespace(),
+		clock:          p.clock,
+	}
+	if c.clock == nil {
+		c.clock = clock.RealClock{}
+	}
+	if err := c.setTransforms(storeSpecKubernetes); err != nil {
+		return nil, err
+	}
 
 	// allow SecretStore controller validation to pass
 	// when using referent namespace.
 	if client.storeKind == esv1beta1.ClusterSecretStoreKind && client.namespace == "" && isReferentSpec(storeSpecKubernetes) {
-		return p, nil
+		return c, nil
 	}
 
-	if err := client.setAuth(ctx); err != nil {
+	if err := c.authenticate(ctx, &client, store); err != nil {
 		return nil, err
 	}
 
-	config := &rest.Config{
-		Host:        client.store.Server.URL,
-		BearerToken: string(client.BearerToken),
-		TLSClientConfig: rest.TLSClientConfig{
-			Insecure: false,
-			CertData: client.Certificate,
-			KeyData:  client.Key,
-			CAData:   client.CA,
-		},
+	cipherSuites, err := cipherSuiteIDs(storeSpecKubernetes.Server.CipherSuites)
+	if err != nil {
+		return nil, err
 	}
-
-	kubeClientSet, err := kubernetes.NewForConfig(config)
+	kubeClientSet, err := clientSetForEndpoints(ctx, &client, clientKey(&client, store), cipherSuites, c.clock.Now())
 	if err != nil {
 		return nil, fmt.Errorf("error configuring clientset: %w", err)
 	}
-	p.Client = kubeClientSet.CoreV1().Secrets(client.store.RemoteNamespace)
-	p.ReviewClient = kubeClientSet.AuthorizationV1().SelfSubjectRulesReviews()
-	return p, nil
+	c.Client = kubeClientSet.CoreV1().Secrets(client.store.RemoteNamespace)
+	c.ReviewClient = kubeClientSet.AuthorizationV1().SelfSubjectRulesReviews()
+	c.AccessReviewClient = kubeClientSet.AuthorizationV1().SelfSubjectAccessReviews()
+	c.ServiceAccountClient = kubeClientSet.CoreV1().ServiceAccounts(client.store.RemoteNamespace)
+	c.NamespaceClient = kubeClientSet.CoreV1().Namespaces()
+	c.MetadataClient = secretMetadataClient{
+		client:    kubeClientSet.CoreV1().RESTClient(),
+		namespace: client.store.RemoteNamespace,
+	}
+	return c, nil
+}
+
+// clientKey identifies the clients of a store for a namespace.
+func clientKey(client *BaseClient, store esv1beta1.GenericStore) string {
+	return strings.Join([]string{client.storeKind, store.GetNamespace(), store.GetName(), client.namespace}, "/")
+}
+
+// authenticate reads the credentials of the store. After consecutive
+// failures further attempts back off, see maxAuthBackoff.
+func (p *ProviderKubernetes) authenticate(ctx context.Context, client *BaseClient, store esv1beta1.GenericStore) error {
+	key := clientKey(client, store)
+	maxBackoff := defaultMaxAuthBackoff
+	if client.store.MaxAuthBackoff != nil {
+		maxBackoff = client.store.MaxAuthBackoff.Duration
+	}
+	if err := checkAuthBackoff(key, p.clock.Now()); err != nil {
+		return err
+	}
+	err := client.setAuth(ctx)
+	recordAuthResult(key, p.clock.Now(), maxBackoff, err)
+	return err
+}
+
+// setTransforms compiles the value and key transformations
+// and the requireLabel selector of the store.
+func (p *ProviderKubernetes) setTransforms(spec *esv1beta1.KubernetesProvider) error {
+	p.valueTemplate = nil
+	if spec.ValueTemplate != "" {
+		valueTpl, err := parseValueTemplate(spec.ValueTemplate)
+		if err != nil {
+			return err
+		}
+		p.valueTemplate = valueTpl
+	}
+	var err error
+	if p.excludeKeys, err = compileKeyRegexp("excludeKeys", spec.ExcludeKeys); err != nil {
+		return err
+	}
+	if p.allowedKeys, err = compileKeyRegexp("allowedKeys", spec.AllowedKeys); err != nil {
+		return err
+	}
+	if p.deniedKeys, err = compileKeyRegexp("deniedKeys", spec.DeniedKeys); err != nil {
+		return err
+	}
+	p.requireLabel = nil
+	if spec.RequireLabel != "" {
+		if p.requireLabel, err = labels.Parse(spec.RequireLabel); err != nil {
+			return fmt.Errorf("unable to parse requireLabel: %w", err)
+		}
+	}
+	return nil
+}
+
+// compileKeyRegexp compiles an optional regular expression of the store.
+func compileKeyRegexp(name, expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", name, err)
+	}
+	return re, nil
+}
+
+// keyAllowed reports whether the allowedKeys and deniedKeys
+// of the store permit reading a data key.
+func (p *ProviderKubernetes) keyAllowed(key string) bool {
+	if p.allowedKeys != nil && !p.allowedKeys.MatchString(key) {
+		return false
+	}
+	return p.deniedKeys == nil || !p.deniedKeys.MatchString(key)
+}
+
+// coalescedGets deduplicates concurrent reads of the same secret.
+var coalescedGets singleflight.Group
+
+// DialContext overrides how connections to the remote API server are made,
+// e.g. to reach edge clusters through an in-process tunnel.
+var DialContext func(ctx context.Context, network, address string) (net.Conn, error)
+
+// newRestConfig returns the config to connect to the remote API server.
+// CAData may contain multiple PEM encoded certificates, all of them are trusted.
+func (k *BaseClient) newRestConfig() *rest.Config {
+	return &rest.Config{
+		Host:        k.store.Server.URL,
+		BearerToken: string(k.BearerToken),
+		Dial:        DialContext,
+		// protobuf reduces the payload size, servers that do not support it answer with JSON
+		ContentConfig: rest.ContentConfig{
+			AcceptContentTypes: runtime.ContentTypeProtobuf + "," + runtime.ContentTypeJSON,
+			ContentType:        runtime.ContentTypeProtobuf,
+		},
+		TLSClientConfig: rest.TLSClientConfig{
+			Insecure:   false,
+			ServerName: k.store.Server.TLSServerName,
+			CertData:   k.Certificate,
+			KeyData:    k.Key,
+			CAData:     k.CA,
+		},
+	}
 }
 
 func isReferentSpec(prov *esv1beta1.KubernetesProvider) bool {
@@ -147,89 +378,1429 @@ func (p *ProviderKubernetes) Close(ctx context.Context) error {
 	return nil
 }
 
+// MinRefreshInterval returns the minRefreshInterval of the store.
+func (p *ProviderKubernetes) MinRefreshInterval(store esv1beta1.GenericStore) time.Duration {
+	spec := store.GetSpec()
+	if spec == nil || spec.Provider == nil || spec.Provider.Kubernetes == nil {
+		return 0
+	}
+	floor := spec.Provider.Kubernetes.MinRefreshInterval
+	if floor == nil || floor.Duration < 0 {
+		return 0
+	}
+	return floor.Duration
+}
+
 func (p *ProviderKubernetes) GetSecret(ctx context.Context, ref esv1beta1.ExternalSecretDataRemoteRef) ([]byte, error) {
-	secretMap, err := p.GetSecretMap(ctx, ref)
+	val, err := p.getSecret(ctx, ref)
+	reportResult(p.genericStore, reasonGetSecretFailed, ref.Key, err)
 	if err != nil {
 		return nil, err
 	}
-	if ref.Property != "" {
-		val, ok := secretMap[ref.Property]
-		if !ok {
-			return nil, fmt.Errorf("property %s does not exist in key %s", ref.Property, ref.Key)
-		}
+	return val, nil
+}
+
+// checkValuePattern returns an error if the value does not match
+// the valuePattern of the ref. The value is never part of the error.
+func checkValuePattern(ref esv1beta1.ExternalSecretDataRemoteRef, val []byte) error {
+	re, err := compileKeyRegexp("valuePattern", ref.ValuePattern)
+	if err != nil || re == nil {
+		return err
+	}
+	if !re.Match(val) {
+		return fmt.Errorf("value of key %s does not match valuePattern %s", ref.Key, ref.ValuePattern)
+	}
+	return nil
+}
+
+// DryRunValidate reads a secret like GetSecret but only returns
+// the length of the value, never the value itself.
+func (p *ProviderKubernetes) DryRunValidate(ctx context.Context, ref esv1beta1.ExternalSecretDataRemoteRef) (int, error) {
+	val, err := p.GetSecret(ctx, ref)
+	if err != nil {
+		return 0, err
+	}
+	return len(val), nil
+}
+
+func (p *ProviderKubernetes) getSecret(ctx context.Context, ref esv1beta1.ExternalSecretDataRemoteRef) ([]byte, error) {
+	ref, err := p.resolveServiceAccountKey(ctx, p.splitKey(ref))
+	if err != nil {
+		return nil, err
+	}
+	secret, err := p.getRemoteSecret(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	p.updateSecretAge(secret)
+	val, single, err := p.getSecretValue(ctx, ref, secret)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkValuePattern(ref, val); err != nil {
+		return nil, err
+	}
+	if !single {
 		return val, nil
 	}
-	strMap := make(map[string]string)
-	for k, v := range secretMap {
-		strMap[k] = string(v)
+	return p.encode(val)
+}
+
+// getSecretValue returns the metadata, a single property or the whole data
+// of the secret. Single values are reported so that the encoding of the
+// store is applied after the property was extracted.
+func (p *ProviderKubernetes) getSecretValue(ctx context.Context, ref esv1beta1.ExternalSecretDataRemoteRef, secret *corev1.Secret) ([]byte, bool, error) {
+	switch ref.MetadataPolicy {
+	case esv1beta1.ExternalSecretMetadataPolicyFetch:
+		val, err := p.getSecretMetadata(secret, ref.Property)
+		return val, false, err
+	case esv1beta1.ExternalSecretMetadataPolicyNone, "":
+		// the metadata prefixes work independent of metadataPolicy and
+		// shadow data keys with the same prefix
+		if val, ok, err := getMetadataProperty(secret, ref.Property); ok {
+			return val, false, err
+		}
+	default:
+		return nil, false, fmt.Errorf("unknown metadataPolicy %s", ref.MetadataPolicy)
+	}
+	data, err := p.secretData(secret)
+	if err != nil {
+		return nil, false, err
+	}
+	if ref.Property == "" {
+		ref.Property = p.defaultProperty(secret)
+	}
+	if ref.Property != "" {
+		val, err := p.getProperty(ctx, ref, data)
+		return val, true, err
+	}
+	val, err := p.renderSecret(data)
+	return val, false, err
+}
+
+// defaultProperty returns the property used by refs without one: the token
+// of service account token secrets with saTokenShortcut, else defaultProperty.
+func (p *ProviderKubernetes) defaultProperty(secret *corev1.Secret) string {
+	if p.saTokenShortcut(secret) {
+		return serviceAccountTokenKey
+	}
+	if p.store != nil {
+		return p.store.DefaultProperty
+	}
+	return ""
+}
+
+// renderSecret returns the whole secret data as JSON or YAML object.
+func (p *ProviderKubernetes) renderSecret(data map[string][]byte) ([]byte, error) {
+	if err := p.checkMaxKeys(data); err != nil {
+		return nil, err
+	}
+	data, err := p.normalizeKeys(data)
+	if err != nil {
+		return nil, err
+	}
+	data, err = p.encodeSerialized(data)
+	if err != nil {
+		return nil, err
+	}
+	if p.store != nil && p.store.RenderFormat == esv1beta1.KubernetesRenderYAML {
+		return renderYAML(data, p.isBinary)
 	}
-	jsonStr, err := json.Marshal(strMap)
+	jsonStr, err := json.Marshal(convertMap(data))
 	if err != nil {
 		return nil, fmt.Errorf("unabled to marshal json: %w", err)
 	}
 	return jsonStr, nil
 }
 
-func (p *ProviderKubernetes) GetSecretMap(ctx context.Context, ref esv1beta1.ExternalSecretDataRemoteRef) (map[string][]byte, error) {
-	secret, err := p.Client.Get(ctx, ref.Key, metav1.GetOptions{})
+// getProperty returns a single value of the secret data.
+func (p *ProviderKubernetes) getProperty(ctx context.Context, ref esv1beta1.ExternalSecretDataRemoteRef, data map[string][]byte) ([]byte, error) {
+	switch ref.Property {
+	case keysProperty:
+		return keyList(data)
+	case pathsProperty:
+		return pathList(data)
+	case tlsProperty:
+		return tlsBundle(data)
+	}
+	if strings.HasPrefix(ref.Property, projectionPrefix) {
+		return p.project(data, strings.TrimPrefix(ref.Property, projectionPrefix))
+	}
+	if key, format, selector, ok := splitPropertyFormat(ref.Property); ok {
+		return p.getFormattedProperty(ref, data, key, format, selector)
+	}
+	if !p.keyAllowed(ref.Property) {
+		return nil, esv1beta1.NoSecretErr
+	}
+	val, ok, err := p.lookupKey(data, ref.Property)
 	if err != nil {
 		return nil, err
 	}
-	return secret.Data, nil
+	if !ok {
+		if val, ok := getJSONPath(data, ref.Property); ok {
+			if p.store != nil && p.store.CoerceToString {
+				return coerceToString(val)
+			}
+			return val, nil
+		}
+		return nil, fmt.Errorf("property %s does not exist in key %s: %w", ref.Property, ref.Key, esv1beta1.NoSecretErr)
+	}
+	if p.store != nil && p.store.Dereference {
+		return p.dereference(ctx, val)
+	}
+	// a present but empty value is returned as such, not as nil
+	if val == nil {
+		val = []byte{}
+	}
+	return val, nil
 }
 
-func (p *ProviderKubernetes) GetAllSecrets(ctx context.Context, ref esv1beta1.ExternalSecretFind) (map[string][]byte, error) {
-	if ref.Tags != nil {
-		return p.findByTags(ctx, ref)
+// tlsBundle returns the certificate, the private key and the optional
+// CA of a `kubernetes.io/tls` secret as JSON object.
+func tlsBundle(data map[string][]byte) ([]byte, error) {
+	var missing []string
+	for _, key := range []string{corev1.TLSCertKey, corev1.TLSPrivateKeyKey} {
+		if _, ok := data[key]; !ok {
+			missing = append(missing, key)
+		}
 	}
-	if ref.Name != nil {
-		return p.findByName(ctx, ref)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("secret is missing the tls keys %s", strings.Join(missing, ", "))
 	}
-	return nil, fmt.Errorf("unexpected find operator: %#v", ref)
+	bundle := struct {
+		Cert string `json:"cert"`
+		Key  string `json:"key"`
+		CA   string `json:"ca,omitempty"`
+	}{
+		Cert: string(data[corev1.TLSCertKey]),
+		Key:  string(data[corev1.TLSPrivateKeyKey]),
+		CA:   string(data[caCertKey]),
+	}
+	return json.Marshal(bundle)
 }
 
-func (p *ProviderKubernetes) findByTags(ctx context.Context, ref esv1beta1.ExternalSecretFind) (map[string][]byte, error) {
-	// empty/nil tags = everything
-	sel, err := labels.ValidatedSelectorFromSet(ref.Tags)
-	if err != nil {
-		return nil, fmt.Errorf("unable to validate selector tags: %w", err)
+// project builds a new JSON object from a projection of the form
+// `{"<target key>":"<source property>"}`. Sources are data keys or
+// JSON properties like `config.db.host`.
+func (p *ProviderKubernetes) project(data map[string][]byte, spec string) ([]byte, error) {
+	var projection map[string]string
+	if err := json.Unmarshal([]byte(spec), &projection); err != nil {
+		return nil, fmt.Errorf("unable to parse projection: %w", err)
 	}
-	secrets, err := p.Client.List(ctx, metav1.ListOptions{LabelSelector: sel.String()})
-	if err != nil {
-		return nil, fmt.Errorf("unable to list secrets: %w", err)
+	out := make(map[string]string, len(projection))
+	for target, source := range projection {
+		val, err := p.projectValue(data, source)
+		if err != nil {
+			return nil, err
+		}
+		out[target] = string(val)
 	}
-	data := make(map[string][]byte)
-	for _, secret := range secrets.Items {
-		jsonStr, err := json.Marshal(convertMap(secret.Data))
+	return json.Marshal(out)
+}
+
+// projectValue returns the value of a projection source.
+func (p *ProviderKubernetes) projectValue(data map[string][]byte, source string) ([]byte, error) {
+	if p.keyAllowed(source) {
+		val, ok, err := p.lookupKey(data, source)
 		if err != nil {
 			return nil, err
 		}
-		data[secret.Name] = jsonStr
+		if ok {
+			return val, nil
+		}
+	}
+	if val, ok := getJSONPath(data, source); ok {
+		return val, nil
 	}
-	return utils.ConvertKeys(ref.ConversionStrategy, data)
+	return nil, fmt.Errorf("projection source %s does not exist: %w", source, esv1beta1.NoSecretErr)
 }
 
-func (p *ProviderKubernetes) findByName(ctx context.Context, ref esv1beta1.ExternalSecretFind) (map[string][]byte, error) {
-	secrets, err := p.Client.List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("unable to list secrets: %w", err)
+// splitPropertyFormat splits a property of the form `key|format:selector`.
+func splitPropertyFormat(property string) (key, format, selector string, ok bool) {
+	idx := strings.Index(property, propertyFormatSeparator)
+	if idx < 0 {
+		return "", "", "", false
+	}
+	key = property[:idx]
+	parts := strings.SplitN(property[idx+len(propertyFormatSeparator):], ":", 2)
+	if len(parts) != 2 {
+		return "", "", "", false
 	}
-	matcher, err := find.New(*ref.Name)
+	return key, parts[0], parts[1], true
+}
+
+// getFormattedProperty parses the value of a data key in the given
+// format and returns the part addressed by the selector.
+func (p *ProviderKubernetes) getFormattedProperty(ref esv1beta1.ExternalSecretDataRemoteRef, data map[string][]byte, key, format, selector string) ([]byte, error) {
+	val, ok, err := p.lookupKey(data, key)
 	if err != nil {
 		return nil, err
 	}
-	data := make(map[string][]byte)
-	for _, secret := range secrets.Items {
-		if !matcher.MatchName(secret.Name) {
+	if !ok {
+		return nil, fmt.Errorf("property %s does not exist in key %s: %w", key, ref.Key, esv1beta1.NoSecretErr)
+	}
+	switch format {
+	case iniFormat:
+		return getINIValue(val, selector)
+	case tarFormat:
+		return getTarEntry(val, selector)
+	}
+	return nil, fmt.Errorf("unknown property format %s", format)
+}
+
+// getINIValue returns the value of `section.key` in an INI document.
+// Section names may contain dots, the key is split at the last dot.
+func getINIValue(doc []byte, selector string) ([]byte, error) {
+	idx := strings.LastIndex(selector, ".")
+	if idx < 0 {
+		return nil, fmt.Errorf("invalid ini selector %s, expected section.key", selector)
+	}
+	section, key := selector[:idx], selector[idx+1:]
+	current := ""
+	sectionFound := false
+	for _, line := range strings.Split(string(doc), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "", strings.HasPrefix(line, ";"), strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			current = strings.TrimSpace(line[1 : len(line)-1])
+			sectionFound = sectionFound || current == section
+			continue
+		}
+		if current != section {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) == key {
+			return []byte(strings.TrimSpace(parts[1])), nil
+		}
+	}
+	if !sectionFound {
+		return nil, fmt.Errorf("ini section %s does not exist: %w", section, esv1beta1.NoSecretErr)
+	}
+	return nil, fmt.Errorf("ini key %s does not exist in section %s: %w", key, section, esv1beta1.NoSecretErr)
+}
+
+// getTarEntry returns the content of a file in a tar archive,
+// which may be gzip compressed. A leading `./` is ignored.
+func getTarEntry(archive []byte, path string) ([]byte, error) {
+	var r io.Reader = bytes.NewReader(archive)
+	if bytes.HasPrefix(archive, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read gzip archive: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	path = strings.TrimPrefix(path, "./")
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("tar entry %s does not exist: %w", path, esv1beta1.NoSecretErr)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read tar archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg || strings.TrimPrefix(hdr.Name, "./") != path {
+			continue
+		}
+		if hdr.Size > maxArchiveEntrySize {
+			return nil, fmt.Errorf("tar entry %s exceeds the maximum size of %d bytes", path, maxArchiveEntrySize)
+		}
+		return io.ReadAll(tr)
+	}
+}
+
+// lookupKey returns the value of a data key. With caseInsensitiveKeys
+// the key is matched ignoring case and an ambiguous match is an error.
+func (p *ProviderKubernetes) lookupKey(data map[string][]byte, key string) ([]byte, bool, error) {
+	if p.store == nil || !p.store.CaseInsensitiveKeys {
+		val, ok := data[key]
+		return val, ok, nil
+	}
+	match := ""
+	for k := range data {
+		if !strings.EqualFold(k, key) {
+			continue
+		}
+		if match != "" {
+			return nil, false, caseCollisionError(match, k)
+		}
+		match = k
+	}
+	if match == "" {
+		return nil, false, nil
+	}
+	return data[match], true, nil
+}
+
+// checkCaseCollisions fails if two keys differ only by case.
+func checkCaseCollisions(data map[string][]byte) error {
+	seen := make(map[string]string, len(data))
+	for k := range data {
+		folded := strings.ToLower(k)
+		if other, exists := seen[folded]; exists {
+			return caseCollisionError(other, k)
+		}
+		seen[folded] = k
+	}
+	return nil
+}
+
+func caseCollisionError(a, b string) error {
+	if b < a {
+		a, b = b, a
+	}
+	return fmt.Errorf("ambiguous keys: %s and %s differ only by case", a, b)
+}
+
+// coerceToString turns all numbers and booleans within a JSON
+// array or object into strings. Other values are returned as they are.
+func coerceToString(val []byte) ([]byte, error) {
+	res := gjson.ParseBytes(val)
+	if !res.IsArray() && !res.IsObject() {
+		return val, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(val))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("unable to parse json: %w", err)
+	}
+	return json.Marshal(stringifyLeaves(v))
+}
+
+func stringifyLeaves(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			t[k] = stringifyLeaves(e)
+		}
+		return t
+	case []interface{}:
+		for i, e := range t {
+			t[i] = stringifyLeaves(e)
+		}
+		return t
+	case json.Number:
+		return t.String()
+	case bool:
+		return strconv.FormatBool(t)
+	}
+	return v
+}
+
+// keyList returns the sorted keys of the data as JSON array.
+func keyList(data map[string][]byte) ([]byte, error) {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return json.Marshal(keys)
+}
+
+// pathList returns the sorted properties that address the data keys and
+// all leaves of their JSON values, e.g. `config.ports.0.port`.
+func pathList(data map[string][]byte) ([]byte, error) {
+	paths := make([]string, 0, len(data))
+	for k, v := range data {
+		paths = append(paths, k)
+		// JSON paths are split from the data key at the first dot
+		if strings.Contains(k, ".") || !gjson.ValidBytes(v) {
 			continue
 		}
-		jsonStr, err := json.Marshal(convertMap(secret.Data))
+		if res := gjson.ParseBytes(v); res.IsObject() || res.IsArray() {
+			paths = appendJSONPaths(paths, k, res)
+		}
+	}
+	sort.Strings(paths)
+	return json.Marshal(paths)
+}
+
+func appendJSONPaths(paths []string, prefix string, res gjson.Result) []string {
+	if !res.IsObject() && !res.IsArray() {
+		return append(paths, prefix)
+	}
+	i := 0
+	res.ForEach(func(key, val gjson.Result) bool {
+		elem := strconv.Itoa(i)
+		if res.IsObject() {
+			elem = gjsonEscape(key.String())
+		}
+		paths = appendJSONPaths(paths, prefix+"."+elem, val)
+		i++
+		return true
+	})
+	return paths
+}
+
+// gjsonEscape escapes the characters that have a meaning in gjson paths.
+func gjsonEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`.*?|#@\`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// getJSONPath resolves a property of the form `key.path`, where path is a gjson
+// query into the JSON value of key, e.g. `users.#.name`. Queries over arrays
+// always return a JSON array, which is empty if nothing matched.
+func getJSONPath(data map[string][]byte, property string) ([]byte, bool) {
+	idx := strings.Index(property, ".")
+	if idx <= 0 {
+		return nil, false
+	}
+	val, ok := data[property[:idx]]
+	if !ok {
+		return nil, false
+	}
+	path := property[idx+1:]
+	res := gjson.GetBytes(val, path)
+	if strings.Contains(path, "#") && !res.IsArray() {
+		return []byte("[]"), true
+	}
+	if !res.Exists() {
+		return nil, false
+	}
+	// numbers are returned as written, res.String() goes through
+	// float64 and would round integers beyond 2^53
+	if res.Type == gjson.Number {
+		return []byte(res.Raw), true
+	}
+	return []byte(res.String()), true
+}
+
+// dereference follows a value of the form `secretRef: namespace/name/key`
+// to the referenced value. References to references are rejected.
+func (p *ProviderKubernetes) dereference(ctx context.Context, val []byte) ([]byte, error) {
+	for depth := 0; ; depth++ {
+		ref, ok := parseSecretRef(val)
+		if !ok {
+			return val, nil
+		}
+		if depth >= maxDereferenceDepth {
+			return nil, fmt.Errorf("secret reference %s exceeds the maximum depth of %d", ref, maxDereferenceDepth)
+		}
+		parts := strings.Split(ref, "/")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid secret reference %s, expected namespace/name/key", ref)
+		}
+		if parts[0] != p.Namespace {
+			return nil, fmt.Errorf("secret reference %s must point to namespace %s", ref, p.Namespace)
+		}
+		// the target is read with the same guards as the referencing secret
+		secret, err := p.getRemoteSecret(ctx, esv1beta1.ExternalSecretDataRemoteRef{Key: parts[1]})
+		if err != nil {
+			return nil, err
+		}
+		data, err := p.secretData(secret)
 		if err != nil {
 			return nil, err
 		}
-		data[secret.Name] = jsonStr
+		val, ok = data[parts[2]]
+		if !ok {
+			return nil, fmt.Errorf("property %s does not exist in key %s", parts[2], parts[1])
+		}
+	}
+}
+
+// parseSecretRef returns the target of a secret reference value.
+func parseSecretRef(val []byte) (string, bool) {
+	str := strings.TrimSpace(string(val))
+	if !strings.HasPrefix(str, secretRefPrefix) {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(str, secretRefPrefix)), true
+}
+
+// getRemoteSecret reads the secret, optionally verifies access to it,
+// skips it if it is terminating and checks its type against expectSecretType.
+func (p *ProviderKubernetes) getRemoteSecret(ctx context.Context, ref esv1beta1.ExternalSecretDataRemoteRef) (*corev1.Secret, error) {
+	secret, err := p.lookupRemoteSecret(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if p.store != nil && p.store.CheckAccessOnRead {
+		if err := p.checkAccess(ctx, secret.Name); err != nil {
+			return nil, err
+		}
+	}
+	if p.skipSecret(secret) {
+		return nil, fmt.Errorf("secret %s is being deleted: %w", secret.Name, esv1beta1.NoSecretErr)
+	}
+	if !p.hasRequiredLabel(secret) {
+		return nil, fmt.Errorf("secret %s does not match requireLabel: %w", secret.Name, esv1beta1.NoSecretErr)
+	}
+	if p.store != nil && p.store.ExpectSecretType != "" && string(secret.Type) != p.store.ExpectSecretType {
+		return nil, fmt.Errorf("secret %s has type %s, expected %s", secret.Name, secret.Type, p.store.ExpectSecretType)
+	}
+	return secret, nil
+}
+
+// saTokenShortcut reports whether the token of a service account
+// token secret is returned instead of the whole secret.
+func (p *ProviderKubernetes) saTokenShortcut(secret *corev1.Secret) bool {
+	return p.store != nil && p.store.SATokenShortcut && secret.Type == corev1.SecretTypeServiceAccountToken
+}
+
+// resolveServiceAccountKey turns a key of the form `sa:<name>` into the
+// token secret of the service account. The property defaults to `token`.
+func (p *ProviderKubernetes) resolveServiceAccountKey(ctx context.Context, ref esv1beta1.ExternalSecretDataRemoteRef) (esv1beta1.ExternalSecretDataRemoteRef, error) {
+	if !strings.HasPrefix(ref.Key, serviceAccountKeyPrefix) {
+		return ref, nil
+	}
+	name, err := p.serviceAccountTokenSecret(ctx, strings.TrimPrefix(ref.Key, serviceAccountKeyPrefix))
+	if err != nil {
+		return ref, err
+	}
+	ref.Key = name
+	if ref.Property == "" {
+		ref.Property = serviceAccountTokenKey
+	}
+	return ref, nil
+}
+
+// serviceAccountTokenSecret returns the name of the token secret
+// a service account references in its secrets.
+func (p *ProviderKubernetes) serviceAccountTokenSecret(ctx context.Context, name string) (string, error) {
+	sa, err := p.ServiceAccountClient.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return "", fmt.Errorf("service account %s not found: %w", name, esv1beta1.NoSecretErr)
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to get service account %s: %w", name, err)
+	}
+	for _, ref := range sa.Secrets {
+		secret, err := p.getByName(ctx, ref.Name)
+		if apierrors.IsNotFound(err) {
+			// service accounts may still reference deleted secrets
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if secret.Type == corev1.SecretTypeServiceAccountToken {
+			return secret.Name, nil
+		}
+	}
+	return "", fmt.Errorf("service account %s has no token secret: %w", name, esv1beta1.NoSecretErr)
+}
+
+// checkAccess verifies that the store identity may get the secret.
+func (p *ProviderKubernetes) checkAccess(ctx context.Context, name string) error {
+	review, err := p.AccessReviewClient.Create(ctx, &authv1.SelfSubjectAccessReview{
+		Spec: authv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authv1.ResourceAttributes{
+				Namespace: p.Namespace,
+				Verb:      "get",
+				Resource:  "secrets",
+				Name:      name,
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("could not verify if client is allowed to get secret %s: %w", name, err)
+	}
+	if !review.Status.Allowed {
+		return fmt.Errorf("client is not allowed to get secret %s: %s", name, review.Status.Reason)
+	}
+	return nil
+}
+
+// skipSecret reports whether the secret is being deleted and skipTerminating is set.
+func (p *ProviderKubernetes) skipSecret(secret *corev1.Secret) bool {
+	return p.store != nil && p.store.SkipTerminating && secret.DeletionTimestamp != nil
+}
+
+// hasRequiredLabel reports whether the labels of the secret match the
+// requireLabel selector of the store, if any.
+func (p *ProviderKubernetes) hasRequiredLabel(secret *corev1.Secret) bool {
+	return p.requireLabel == nil || p.requireLabel.Matches(labels.Set(secret.Labels))
+}
+
+// lookupRemoteSecret reads the secret by name, or the single secret
+// matching the label selector if the key is empty.
+func (p *ProviderKubernetes) lookupRemoteSecret(ctx context.Context, ref esv1beta1.ExternalSecretDataRemoteRef) (*corev1.Secret, error) {
+	if ref.Key != "" || ref.LabelSelector == "" {
+		return p.getByName(ctx, ref.Key)
+	}
+	sel, err := labels.Parse(ref.LabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse label selector: %w", err)
+	}
+	// two results are enough to detect an ambiguous selector
+	secrets, err := p.Client.List(ctx, metav1.ListOptions{LabelSelector: sel.String(), Limit: 2})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list secrets: %w", err)
+	}
+	switch len(secrets.Items) {
+	case 0:
+		return nil, fmt.Errorf("no secret matches label selector %s: %w", ref.LabelSelector, esv1beta1.NoSecretErr)
+	case 1:
+		return &secrets.Items[0], nil
+	}
+	return nil, fmt.Errorf("more than one secret matches label selector %s", ref.LabelSelector)
+}
+
+// getByName reads a secret. With coalesceReads concurrent reads of the same
+// secret through the same store share one API call, every caller gets a copy.
+func (p *ProviderKubernetes) getByName(ctx context.Context, name string) (*corev1.Secret, error) {
+	if p.store == nil || !p.store.CoalesceReads {
+		return p.Client.Get(ctx, name, metav1.GetOptions{})
+	}
+	key := strings.Join([]string{p.storeKind, p.storeNamespace, p.storeName, p.Namespace, name}, "/")
+	v, err, _ := coalescedGets.Do(key, func() (interface{}, error) {
+		return p.Client.Get(ctx, name, metav1.GetOptions{})
+	})
+	if err != nil {
+		return nil, err
+	}
+	secret, ok := v.(*corev1.Secret)
+	if !ok {
+		return nil, fmt.Errorf("unexpected result type %T", v)
+	}
+	return secret.DeepCopy(), nil
+}
+
+// splitKey splits the key into secret name and property
+// at the first keyPropertySeparator if no property is set.
+func (p *ProviderKubernetes) splitKey(ref esv1beta1.ExternalSecretDataRemoteRef) esv1beta1.ExternalSecretDataRemoteRef {
+	if p.store == nil || p.store.KeyPropertySeparator == "" || ref.Property != "" {
+		return ref
+	}
+	parts := strings.SplitN(ref.Key, p.store.KeyPropertySeparator, 2)
+	if len(parts) == 2 {
+		ref.Key = parts[0]
+		ref.Property = parts[1]
+	}
+	return ref
+}
+
+// getSecretMetadata returns a label, an annotation or a computed value of
+// the secret. Without a property all labels and annotations are returned.
+// Trim and valueTemplate are not applied to labels and annotations.
+func (p *ProviderKubernetes) getSecretMetadata(secret *corev1.Secret, property string) ([]byte, error) {
+	switch {
+	case property == "":
+		jsonStr, err := json.Marshal(map[string]map[string]string{
+			"annotations": secret.Annotations,
+			"labels":      secret.Labels,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unabled to marshal json: %w", err)
+		}
+		return jsonStr, nil
+	case strings.HasPrefix(property, metadataAnnotationsPrefix), strings.HasPrefix(property, metadataLabelsPrefix):
+		val, _, err := getMetadataProperty(secret, property)
+		return val, err
+	case strings.HasPrefix(property, managedFieldsPrefix) && strings.HasSuffix(property, managedFieldsTimeSuffix):
+		key := strings.TrimSuffix(strings.TrimPrefix(property, managedFieldsPrefix), managedFieldsTimeSuffix)
+		return getKeyModifiedTime(secret, key)
+	case property == namespaceProperty:
+		return []byte(secret.Namespace), nil
+	case property == deletionTimestampProperty:
+		if secret.DeletionTimestamp == nil {
+			return []byte{}, nil
+		}
+		return []byte(secret.DeletionTimestamp.UTC().Format(time.RFC3339)), nil
+	case property == finalizersProperty:
+		return json.Marshal(append([]string{}, secret.Finalizers...))
+	case property == ownerCountProperty:
+		return []byte(strconv.Itoa(len(secret.OwnerReferences))), nil
+	}
+	data, err := p.secretData(secret)
+	if err != nil {
+		return nil, err
+	}
+	if val, ok := getComputedValue(property, data, p.isBinary); ok {
+		return val, nil
+	}
+	return nil, fmt.Errorf("unknown metadata property %s", property)
+}
+
+// getKeyModifiedTime returns the time a data key was last written
+// according to the managedFields of the secret.
+func getKeyModifiedTime(secret *corev1.Secret, key string) ([]byte, error) {
+	last := keyModifiedTime(secret, key)
+	if last == nil {
+		return nil, esv1beta1.NoSecretErr
+	}
+	return []byte(last.UTC().Format(time.RFC3339)), nil
+}
+
+// keyModifiedTime returns the latest managedFields time that wrote the key, or nil.
+func keyModifiedTime(secret *corev1.Secret, key string) *metav1.Time {
+	var last *metav1.Time
+	for i := range secret.ManagedFields {
+		entry := &secret.ManagedFields[i]
+		if entry.Time == nil || entry.FieldsV1 == nil {
+			continue
+		}
+		var fields map[string]map[string]json.RawMessage
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		if _, ok := fields["f:data"]["f:"+key]; !ok {
+			continue
+		}
+		if last == nil || entry.Time.After(last.Time) {
+			last = entry.Time
+		}
+	}
+	return last
+}
+
+// getMetadataProperty returns a label or an annotation of the secret
+// if the property has one of the metadata prefixes.
+func getMetadataProperty(secret *corev1.Secret, property string) ([]byte, bool, error) {
+	switch {
+	case strings.HasPrefix(property, metadataAnnotationsPrefix):
+		val, err := getMetadataValue(secret.Annotations, strings.TrimPrefix(property, metadataAnnotationsPrefix))
+		return val, true, err
+	case strings.HasPrefix(property, metadataLabelsPrefix):
+		val, err := getMetadataValue(secret.Labels, strings.TrimPrefix(property, metadataLabelsPrefix))
+		return val, true, err
+	}
+	return nil, false, nil
+}
+
+// getMetadataValue returns the value of a label or annotation.
+func getMetadataValue(m map[string]string, key string) ([]byte, error) {
+	val, ok := m[key]
+	if !ok {
+		return nil, esv1beta1.NoSecretErr
+	}
+	return []byte(val), nil
+}
+
+// getComputedValue returns the number of keys, the total size
+// of the values, a checksum, the duplicate values, the binary keys
+// or a manifest of the key sizes of a secret.
+func getComputedValue(property string, data map[string][]byte, isBinary func([]byte) bool) ([]byte, bool) {
+	switch property {
+	case checksumProperty:
+		// json.Marshal sorts the keys, so the checksum is stable
+		jsonData, err := json.Marshal(data)
+		if err != nil {
+			return nil, false
+		}
+		sum := sha256.Sum256(jsonData)
+		return []byte(hex.EncodeToString(sum[:])), true
+	case keyCountProperty:
+		return []byte(strconv.Itoa(len(data))), true
+	case byteSizeProperty:
+		size := 0
+		for _, v := range data {
+			size += len(v)
+		}
+		return []byte(strconv.Itoa(size)), true
+	case deduplicateProperty:
+		jsonData, err := json.Marshal(duplicateKeys(data))
+		if err != nil {
+			return nil, false
+		}
+		return jsonData, true
+	case binaryKeysProperty:
+		jsonData, err := json.Marshal(binaryKeys(data, isBinary))
+		if err != nil {
+			return nil, false
+		}
+		return jsonData, true
+	case manifestProperty:
+		manifest := make(map[string]int, len(data))
+		for k, v := range data {
+			manifest[k] = len(v)
+		}
+		jsonData, err := json.Marshal(manifest)
+		if err != nil {
+			return nil, false
+		}
+		return jsonData, true
+	}
+	return nil, false
+}
+
+// binaryKeys returns the sorted keys whose values are reported as binary by isBinary.
+func binaryKeys(data map[string][]byte, isBinary func([]byte) bool) []string {
+	keys := []string{}
+	for k, v := range data {
+		if isBinary(v) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// duplicateKeys groups the keys that share a value. Values are compared
+// by their sha256 hash and are never part of the result. Only groups with
+// more than one key are returned, sorted by their first key.
+func duplicateKeys(data map[string][]byte) [][]string {
+	byHash := make(map[[sha256.Size]byte][]string)
+	for k, v := range data {
+		sum := sha256.Sum256(v)
+		byHash[sum] = append(byHash[sum], k)
+	}
+	groups := [][]string{}
+	for _, keys := range byHash {
+		if len(keys) < 2 {
+			continue
+		}
+		sort.Strings(keys)
+		groups = append(groups, keys)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i][0] < groups[j][0]
+	})
+	return groups
+}
+
+func (p *ProviderKubernetes) GetSecretMap(ctx context.Context, ref esv1beta1.ExternalSecretDataRemoteRef) (map[string][]byte, error) {
+	secret, err := p.getRemoteSecret(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	p.updateSecretAge(secret)
+	data, err := p.secretData(secret)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkMaxKeys(data); err != nil {
+		return nil, err
+	}
+	data, err = p.normalizeKeys(data)
+	if err != nil {
+		return nil, err
+	}
+	data, err = p.checkKeyNames(data)
+	if err != nil {
+		return nil, err
+	}
+	return p.encodeData(data)
+}
+
+// checkKeyNames rejects keys that can not be used in a volume mount.
+// With sanitizeKeys they are rewritten instead and the changes are logged.
+func (p *ProviderKubernetes) checkKeyNames(in map[string][]byte) (map[string][]byte, error) {
+	sanitize := p.store != nil && p.store.SanitizeKeys
+	out := make(map[string][]byte, len(in))
+	sources := make(map[string]string, len(in))
+	for k, v := range in {
+		key := k
+		if errs := validation.IsConfigMapKey(k); len(errs) > 0 {
+			if !sanitize {
+				return nil, fmt.Errorf("invalid key %s: %s", k, strings.Join(errs, ", "))
+			}
+			key = sanitizeKey(k)
+			if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+				return nil, fmt.Errorf("invalid key %s after sanitizing: %s", k, strings.Join(errs, ", "))
+			}
+			log.Info("sanitized key", "key", k, "sanitized", key)
+		}
+		if source, exists := sources[key]; exists {
+			first, second := source, k
+			if second < first {
+				first, second = second, first
+			}
+			return nil, fmt.Errorf("key collision after sanitizing: %s and %s both result in %s", first, second, key)
+		}
+		sources[key] = k
+		out[key] = v
+	}
+	return out, nil
+}
+
+// sanitizeKey replaces all characters that are not allowed in a
+// secret key and the leading dots of a `..` prefix with `_`.
+func sanitizeKey(key string) string {
+	var b strings.Builder
+	leading := strings.HasPrefix(key, "..") || key == "."
+	for _, r := range key {
+		switch {
+		case leading && r == '.':
+			b.WriteRune('_')
+			continue
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+		leading = false
+	}
+	return b.String()
+}
+
+// checkMaxKeys fails if the data has more keys than maxKeys allows.
+func (p *ProviderKubernetes) checkMaxKeys(data map[string][]byte) error {
+	if p.store == nil || p.store.MaxKeys <= 0 || len(data) <= p.store.MaxKeys {
+		return nil
+	}
+	return fmt.Errorf("secret has %d keys, more than the maximum of %d", len(data), p.store.MaxKeys)
+}
+
+// normalizeKeys turns the keys into valid environment variable
+// names if envCompatibleKeys is set on the store. With caseInsensitiveKeys
+// keys that differ only by case are rejected.
+func (p *ProviderKubernetes) normalizeKeys(in map[string][]byte) (map[string][]byte, error) {
+	if p.store != nil && p.store.CaseInsensitiveKeys {
+		if err := checkCaseCollisions(in); err != nil {
+			return nil, err
+		}
+	}
+	if p.store == nil || !p.store.EnvCompatibleKeys {
+		return in, nil
+	}
+	out := make(map[string][]byte, len(in))
+	sources := make(map[string]string, len(in))
+	for k, v := range in {
+		key := envKey(k)
+		if source, exists := sources[key]; exists {
+			first, second := source, k
+			if second < first {
+				first, second = second, first
+			}
+			return nil, fmt.Errorf("key collision after normalization: %s and %s both result in %s", first, second, key)
+		}
+		sources[key] = k
+		out[key] = v
+	}
+	return out, nil
+}
+
+// envKey uppercases a key and replaces all characters
+// that are not allowed in environment variable names.
+func envKey(key string) string {
+	var b strings.Builder
+	for i, r := range strings.ToUpper(key) {
+		switch {
+		case r >= 'A' && r <= 'Z', r == '_':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteRune('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+// secretData returns the data of the secret with the key
+// and value transformations of the store applied. The encoding of
+// the store is applied later to the returned values.
+// Binary values are not trimmed or templated. With verifyHash the
+// raw values are checked against their hash annotations.
+func (p *ProviderKubernetes) secretData(secret *corev1.Secret) (map[string][]byte, error) {
+	// a secret without data is treated as an empty secret
+	if secret.Data == nil {
+		return map[string][]byte{}, nil
+	}
+	if p.store == nil {
+		return secret.Data, nil
+	}
+	data := make(map[string][]byte, len(secret.Data))
+	for k, v := range secret.Data {
+		if !p.keyAllowed(k) || (p.excludeKeys != nil && p.excludeKeys.MatchString(k)) {
+			continue
+		}
+		if p.store.VerifyHash {
+			if err := verifyHash(secret, k); err != nil {
+				return nil, err
+			}
+		}
+		val, err := p.transformValue(k, p.decodeValue(v))
+		if err != nil {
+			return nil, err
+		}
+		data[k] = val
+	}
+	return data, nil
+}
+
+// transformValue trims and templates a value unless it is binary.
+func (p *ProviderKubernetes) transformValue(key string, val []byte) ([]byte, error) {
+	if p.isBinary(val) {
+		return val, nil
+	}
+	val = trimValue(p.store.Trim, val)
+	if p.valueTemplate == nil {
+		return val, nil
+	}
+	var buf bytes.Buffer
+	if err := p.valueTemplate.Execute(&buf, map[string]string{"Value": string(val)}); err != nil {
+		return nil, fmt.Errorf("unable to execute valueTemplate for key %s: %w", key, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// encode applies the encoding of the store to a single returned value.
+func (p *ProviderKubernetes) encode(val []byte) ([]byte, error) {
+	if p.store == nil {
+		return val, nil
+	}
+	val, err := encodeValue(p.store.Encode, val)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode value: %w", err)
+	}
+	return val, nil
+}
+
+// encodeData applies the encoding of the store to every value of the data.
+func (p *ProviderKubernetes) encodeData(data map[string][]byte) (map[string][]byte, error) {
+	if p.store == nil || p.store.Encode == "" || p.store.Encode == esv1beta1.KubernetesEncodingNone {
+		return data, nil
+	}
+	out := make(map[string][]byte, len(data))
+	for k, v := range data {
+		val, err := encodeValue(p.store.Encode, v)
+		if err != nil {
+			return nil, fmt.Errorf("unable to encode key %s: %w", k, err)
+		}
+		out[k] = val
+	}
+	return out, nil
+}
+
+// encodeSerialized applies the encoding of the store to values that are
+// serialized as JSON or YAML. Compressed values can not be embedded there.
+func (p *ProviderKubernetes) encodeSerialized(data map[string][]byte) (map[string][]byte, error) {
+	if p.store != nil && p.store.Encode == esv1beta1.KubernetesEncodingGzip {
+		return nil, errGzipSerialized
+	}
+	return p.encodeData(data)
+}
+
+// encodeValue encodes a value with the given encoding.
+func encodeValue(encoding esv1beta1.KubernetesEncoding, val []byte) ([]byte, error) {
+	var enc *base64.Encoding
+	switch encoding {
+	case esv1beta1.KubernetesEncodingBase64:
+		enc = base64.StdEncoding
+	case esv1beta1.KubernetesEncodingBase64URL:
+		enc = base64.URLEncoding
+	case esv1beta1.KubernetesEncodingBase64URLNoPad:
+		enc = base64.RawURLEncoding
+	case esv1beta1.KubernetesEncodingGzip:
+		return gzipValue(val)
+	case esv1beta1.KubernetesEncodingHex:
+		return []byte(hex.EncodeToString(val)), nil
+	case esv1beta1.KubernetesEncodingNone:
+	}
+	if enc == nil {
+		return val, nil
+	}
+	out := make([]byte, enc.EncodedLen(len(val)))
+	enc.Encode(out, val)
+	return out, nil
+}
+
+// gzipValue compresses a value with gzip.
+func gzipValue(val []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(val); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// verifyHash compares the sha256 of a data key with its hash annotation.
+func verifyHash(secret *corev1.Secret, key string) error {
+	want, ok := secret.Annotations[hashAnnotationPrefix+key]
+	if !ok {
+		return fmt.Errorf("secret %s has no hash annotation for key %s", secret.Name, key)
+	}
+	sum := sha256.Sum256(secret.Data[key])
+	if !strings.EqualFold(hex.EncodeToString(sum[:]), strings.TrimSpace(want)) {
+		return fmt.Errorf("hash of key %s in secret %s does not match its annotation", key, secret.Name)
+	}
+	return nil
+}
+
+func parseValueTemplate(text string) (*tpl.Template, error) {
+	t, err := tpl.New("valueTemplate").Funcs(template.FuncMap()).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse valueTemplate: %w", err)
+	}
+	return t, nil
+}
+
+// trimValue removes trailing whitespace or newlines from a value.
+func trimValue(strategy esv1beta1.KubernetesTrimStrategy, val []byte) []byte {
+	switch strategy {
+	case esv1beta1.KubernetesTrimWhitespace:
+		return bytes.TrimRightFunc(val, unicode.IsSpace)
+	case esv1beta1.KubernetesTrimNewline:
+		return bytes.TrimRight(val, "\r\n")
+	case esv1beta1.KubernetesTrimNone:
+	}
+	return val
+}
+
+// decodeValue converts latin1 values to UTF-8 when the store
+// declares them as such, other values are returned unaltered.
+func (p *ProviderKubernetes) decodeValue(val []byte) []byte {
+	if p.store.ValueEncoding != esv1beta1.KubernetesValueEncodingLatin1 {
+		return val
+	}
+	// every latin1 byte maps to the unicode code point of the same value
+	runes := make([]rune, len(val))
+	for i, b := range val {
+		runes[i] = rune(b)
+	}
+	return []byte(string(runes))
+}
+
+// isBinary reports whether a value is binary according to the
+// valueEncoding of the store, falling back to detection.
+func (p *ProviderKubernetes) isBinary(val []byte) bool {
+	if p.store == nil {
+		return isBinary(val)
+	}
+	switch p.store.ValueEncoding {
+	case esv1beta1.KubernetesValueEncodingBinary:
+		return true
+	case esv1beta1.KubernetesValueEncodingUTF8, esv1beta1.KubernetesValueEncodingLatin1:
+		return false
+	case esv1beta1.KubernetesValueEncodingAuto:
+	}
+	return isBinary(val)
+}
+
+// isBinary reports whether a value is not valid UTF-8 or
+// contains NUL or control characters other than whitespace.
+func isBinary(val []byte) bool {
+	if !utf8.Valid(val) {
+		return true
+	}
+	for _, r := range string(val) {
+		if unicode.IsControl(r) && !unicode.IsSpace(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *ProviderKubernetes) GetAllSecrets(ctx context.Context, ref esv1beta1.ExternalSecretFind) (map[string][]byte, error) {
+	data := make(map[string][]byte)
+	err := p.GetAllSecretsStream(ctx, ref, func(key string, value []byte) error {
+		if ref.MaxResults > 0 && len(data) >= ref.MaxResults {
+			return errMaxResults
+		}
+		data[key] = value
+		return nil
+	})
+	var truncated error
+	if errors.Is(err, errMaxResults) {
+		log.Info("find result truncated", "namespace", p.Namespace, "maxResults", ref.MaxResults)
+		truncated = fmt.Errorf("find result truncated, more than maxResults %d secrets match", ref.MaxResults)
+		err = nil
+	}
+	if err == nil {
+		reportResult(p.genericStore, reasonFindTruncated, findKey(ref), truncated)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 && p.store != nil && p.store.EmptyResultPolicy == esv1beta1.KubernetesEmptyResultError {
+		return nil, fmt.Errorf("no secrets matched in namespace %s", p.Namespace)
+	}
+	return data, nil
+}
+
+// findKey identifies a find in the reported errors.
+func findKey(ref esv1beta1.ExternalSecretFind) string {
+	if ref.Name != nil {
+		return "name=" + ref.Name.RegExp
+	}
+	return "tags=" + labels.Set(ref.Tags).String()
+}
+
+// GetAllSecretsStream lists the secrets matching ref page by page and calls fn
+// for every secret, so callers do not have to hold all secrets in memory.
+// It stops at the first error returned by fn or when ctx is done.
+// No goroutines are started, the stream runs in the caller's goroutine.
+func (p *ProviderKubernetes) GetAllSecretsStream(ctx context.Context, ref esv1beta1.ExternalSecretFind, fn func(key string, value []byte) error) error {
+	opts, matcher, err := findListOptions(ref)
+	if err != nil {
+		return err
+	}
+	keys := newKeyTracker(ref)
+	if p.store != nil && p.store.ListMetadataOnly {
+		return p.streamByMetadata(ctx, opts, matcher, keys, fn)
+	}
+	for {
+		// fn may be slow, stop before requesting the next page once ctx is done
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		secrets, err := p.Client.List(ctx, opts)
+		if err != nil {
+			return fmt.Errorf("unable to list secrets: %w", err)
+		}
+		for i := range secrets.Items {
+			secret := &secrets.Items[i]
+			if !p.findMatches(secret, matcher) {
+				continue
+			}
+			if err := p.streamSecret(secret, keys, fn); err != nil {
+				return err
+			}
+		}
+		if secrets.Continue == "" {
+			return nil
+		}
+		opts.Continue = secrets.Continue
+	}
+}
+
+// findMatches reports whether a listed secret is part of the find result.
+func (p *ProviderKubernetes) findMatches(secret *corev1.Secret, matcher *find.Matcher) bool {
+	return (matcher == nil || matcher.MatchName(secret.Name)) && !p.skipSecret(secret) && p.hasRequiredLabel(secret)
+}
+
+// findListOptions returns the list options and the optional
+// name matcher for a find operator.
+func findListOptions(ref esv1beta1.ExternalSecretFind) (metav1.ListOptions, *find.Matcher, error) {
+	opts := metav1.ListOptions{Limit: listPageSize}
+	if ref.Tags != nil {
+		// empty/nil tags = everything
+		sel, err := labels.ValidatedSelectorFromSet(ref.Tags)
+		if err != nil {
+			return opts, nil, fmt.Errorf("unable to validate selector tags: %w", err)
+		}
+		opts.LabelSelector = sel.String()
+		return opts, nil, nil
+	}
+	if ref.Name != nil {
+		matcher, err := find.New(*ref.Name)
+		return opts, matcher, err
+	}
+	return opts, nil, fmt.Errorf("unexpected find operator: %#v", ref)
+}
+
+// streamSecret passes the data of a found secret to fn.
+func (p *ProviderKubernetes) streamSecret(secret *corev1.Secret, keys *keyTracker, fn func(key string, value []byte) error) error {
+	secretData, err := p.secretData(secret)
+	if err != nil {
+		return err
+	}
+	if since := keys.ref.ModifiedSince; since != nil {
+		secretData = modifiedSince(secret, secretData, since.Time)
+		if len(secretData) == 0 {
+			return nil
+		}
+	}
+	if len(secretData) == 0 && !keys.ref.IncludeEmpty {
+		return nil
+	}
+	secretData, err = p.encodeSerialized(secretData)
+	if err != nil {
+		return err
+	}
+	jsonStr, err := json.Marshal(convertMap(secretData))
+	if err != nil {
+		return err
+	}
+	key, err := keys.add(secret.Name)
+	if err != nil {
+		return err
+	}
+	return fn(key, jsonStr)
+}
+
+// modifiedSince returns the keys that were modified after the cutoff according
+// to the managedFields of the secret. Keys without a managedFields entry
+// fall back to the creationTimestamp of the secret.
+func modifiedSince(secret *corev1.Secret, data map[string][]byte, cutoff time.Time) map[string][]byte {
+	out := make(map[string][]byte, len(data))
+	for k, v := range data {
+		modified := secret.CreationTimestamp.Time
+		if t := keyModifiedTime(secret, k); t != nil {
+			modified = t.Time
+		}
+		if modified.After(cutoff) {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// keyTracker strips and converts the names of the found secrets
+// and detects collisions between them.
+type keyTracker struct {
+	ref       esv1beta1.ExternalSecretFind
+	stripped  map[string]string
+	converted map[string]bool
+}
+
+func newKeyTracker(ref esv1beta1.ExternalSecretFind) *keyTracker {
+	return &keyTracker{
+		ref:       ref,
+		stripped:  make(map[string]string),
+		converted: make(map[string]bool),
+	}
+}
+
+// add returns the key for a secret name.
+func (t *keyTracker) add(name string) (string, error) {
+	key := name
+	if t.ref.StripPrefix != "" || t.ref.StripSuffix != "" {
+		key = strings.TrimSuffix(strings.TrimPrefix(name, t.ref.StripPrefix), t.ref.StripSuffix)
+		if key == "" {
+			return "", fmt.Errorf("secret name %s is empty after stripping", name)
+		}
+		if source, exists := t.stripped[key]; exists {
+			first, second := source, name
+			if second < first {
+				first, second = second, first
+			}
+			return "", fmt.Errorf("secret name collision after stripping: %s and %s both result in %s", first, second, key)
+		}
+		t.stripped[key] = name
+	}
+	key = utils.ConvertKey(t.ref.ConversionStrategy, key)
+	if t.converted[key] {
+		return "", fmt.Errorf("secret name collision during conversion: %s", key)
+	}
+	t.converted[key] = true
+	return key, nil
+}
+
+// renderYAML renders the data as YAML mapping, the keys are sorted.
+// Values reported as binary by isBinary are base64 encoded and
+// tagged with !!binary.
+func renderYAML(data map[string][]byte, isBinary func([]byte) bool) ([]byte, error) {
+	out := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		if isBinary(v) {
+			out[k] = &yaml.Node{
+				Kind:  yaml.ScalarNode,
+				Tag:   "!!binary",
+				Value: base64.StdEncoding.EncodeToString(v),
+			}
+			continue
+		}
+		out[k] = string(v)
+	}
+	yamlStr, err := yaml.Marshal(out)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal yaml: %w", err)
 	}
-	return utils.ConvertKeys(ref.ConversionStrategy, data)
+	return yamlStr, nil
 }
 
 func convertMap(in map[string][]byte) map[string]string {
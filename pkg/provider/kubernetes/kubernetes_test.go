@@ -14,14 +14,37 @@ limitations under the License.
 package kubernetes
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	authv1 "k8s.io/api/authorization/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
 	"k8s.io/utils/pointer"
 	kclient "sigs.k8s.io/controller-runtime/pkg/client"
 	fclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -69,7 +92,11 @@ func (fk fakeClient) Get(ctx context.Context, name string, opts metav1.GetOption
 }
 
 func (fk fakeClient) List(ctx context.Context, opts metav1.ListOptions) (*corev1.SecretList, error) {
-	assert.Equal(fk.t, fk.expectedListOptions, opts)
+	expected := fk.expectedListOptions
+	if expected.Limit == 0 {
+		expected.Limit = listPageSize
+	}
+	assert.Equal(fk.t, expected, opts)
 	list := &corev1.SecretList{}
 	for _, v := range fk.secretMap {
 		list.Items = append(list.Items, v)
@@ -82,14 +109,16 @@ func TestGetSecret(t *testing.T) {
 		Client       KClient
 		ReviewClient RClient
 		Namespace    string
+		store        *esv1beta1.KubernetesProvider
 	}
 	tests := []struct {
 		name   string
 		fields fields
 		ref    esv1beta1.ExternalSecretDataRemoteRef
 
-		want    []byte
-		wantErr bool
+		want      []byte
+		wantErr   bool
+		wantErrIs error
 	}{
 		{
 			name: "err GetSecretMap",
@@ -168,296 +197,3856 @@ func TestGetSecret(t *testing.T) {
 			},
 			want: []byte(`{"token":"foobar"}`),
 		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			p := &ProviderKubernetes{
-				Client:       tt.fields.Client,
-				ReviewClient: tt.fields.ReviewClient,
-				Namespace:    tt.fields.Namespace,
-			}
-			got, err := p.GetSecret(context.Background(), tt.ref)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("ProviderKubernetes.GetSecret() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("ProviderKubernetes.GetSecret() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
-func TestNewClient(t *testing.T) {
-	type fields struct {
-		Client       KClient
-		ReviewClient RClient
-		Namespace    string
-	}
-	type args struct {
-		store     esv1beta1.GenericStore
-		kube      kclient.Client
-		namespace string
-	}
-	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		want    bool
-		wantErr bool
-	}{
 		{
-			name:   "invalid store",
-			fields: fields{},
-			args: args{
-				store: &esv1beta1.ClusterSecretStore{
-					TypeMeta: metav1.TypeMeta{
-						Kind: esv1beta1.ClusterSecretStoreKind,
-					},
-					Spec: esv1beta1.SecretStoreSpec{
-						Provider: &esv1beta1.SecretStoreProvider{},
+			name: "annotation as property",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							ObjectMeta: metav1.ObjectMeta{
+								Annotations: map[string]string{
+									"example.com/owner": "team-a",
+								},
+							},
+							Data: map[string][]byte{
+								"token": []byte(`foobar`),
+							},
+						},
 					},
 				},
-				kube: fclient.NewClientBuilder().Build(),
+				Namespace: "default",
 			},
-			wantErr: true,
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "metadata.annotations.example.com/owner",
+			},
+			want: []byte(`team-a`),
 		},
 		{
-			name:   "test referent auth return",
-			fields: fields{},
-			args: args{
-				store: &esv1beta1.ClusterSecretStore{
-					TypeMeta: metav1.TypeMeta{
-						Kind: esv1beta1.ClusterSecretStoreKind,
-					},
-					Spec: esv1beta1.SecretStoreSpec{
-						Provider: &esv1beta1.SecretStoreProvider{
-							Kubernetes: &esv1beta1.KubernetesProvider{
-								Server: esv1beta1.KubernetesServer{
-									CABundle: []byte(testCertificate),
-								},
-								Auth: esv1beta1.KubernetesAuth{
-									Token: &esv1beta1.TokenAuth{
-										BearerToken: v1.SecretKeySelector{
-											Name: "foo",
-											Key:  "token",
-										},
-									},
+			name: "label as property",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							ObjectMeta: metav1.ObjectMeta{
+								Labels: map[string]string{
+									"app": "nginx",
 								},
 							},
 						},
 					},
 				},
-				namespace: "",
-				kube:      fclient.NewClientBuilder().Build(),
+				Namespace: "default",
 			},
-			want: true,
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "metadata.labels.app",
+			},
+			want: []byte(`nginx`),
 		},
 		{
-			name:   "auth fail results in error",
-			fields: fields{},
-			args: args{
-				store: &esv1beta1.ClusterSecretStore{
-					TypeMeta: metav1.TypeMeta{
-						Kind: esv1beta1.ClusterSecretStoreKind,
-					},
-					Spec: esv1beta1.SecretStoreSpec{
-						Provider: &esv1beta1.SecretStoreProvider{
-							Kubernetes: &esv1beta1.KubernetesProvider{
-								Server: esv1beta1.KubernetesServer{
-									CABundle: []byte(testCertificate),
-								},
-								RemoteNamespace: "remote",
-								Auth: esv1beta1.KubernetesAuth{
-									Token: &esv1beta1.TokenAuth{
-										BearerToken: v1.SecretKeySelector{
-											Name:      "foo",
-											Namespace: pointer.String("default"),
-											Key:       "token",
-										},
-									},
-								},
+			name: "missing annotation",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"token": []byte(`foobar`),
 							},
 						},
 					},
 				},
-				namespace: "foobarothernamespace",
-				kube:      fclient.NewClientBuilder().Build(),
+				Namespace: "default",
 			},
-			wantErr: true,
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "metadata.annotations.example.com/owner",
+			},
+			wantErr:   true,
+			wantErrIs: esv1beta1.NoSecretErr,
 		},
 		{
-			name:   "test auth",
-			fields: fields{},
-			args: args{
-				store: &esv1beta1.ClusterSecretStore{
-					TypeMeta: metav1.TypeMeta{
-						Kind: esv1beta1.ClusterSecretStoreKind,
-					},
-					Spec: esv1beta1.SecretStoreSpec{
-						Provider: &esv1beta1.SecretStoreProvider{
-							Kubernetes: &esv1beta1.KubernetesProvider{
-								Server: esv1beta1.KubernetesServer{
-									CABundle: []byte(testCertificate),
-								},
-								RemoteNamespace: "remote",
-								Auth: esv1beta1.KubernetesAuth{
-									Token: &esv1beta1.TokenAuth{
-										BearerToken: v1.SecretKeySelector{
-											Name:      "foo",
-											Namespace: pointer.String("default"),
-											Key:       "token",
-										},
-									},
+			name: "annotation as property with policy fetch",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							ObjectMeta: metav1.ObjectMeta{
+								Annotations: map[string]string{
+									"example.com/owner": "team-a",
 								},
 							},
 						},
 					},
 				},
-				namespace: "foobarothernamespace",
-				kube: fclient.NewClientBuilder().WithObjects(&corev1.Secret{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      "foo",
-						Namespace: "default",
-					},
-					Data: map[string][]byte{
-						"token": []byte("1234"),
-					},
-				}).Build(),
+				Namespace: "default",
 			},
-			want: true,
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:            "mysec",
+				Property:       "metadata.annotations.example.com/owner",
+				MetadataPolicy: esv1beta1.ExternalSecretMetadataPolicyFetch,
+			},
+			want: []byte(`team-a`),
 		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			p := &ProviderKubernetes{
-				Client:       tt.fields.Client,
-				ReviewClient: tt.fields.ReviewClient,
-				Namespace:    tt.fields.Namespace,
-			}
-			got, err := p.NewClient(context.Background(), tt.args.store, tt.args.kube, tt.args.namespace)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("ProviderKubernetes.NewClient() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if tt.want {
-				assert.NotNil(t, got)
-			} else {
-				assert.Nil(t, got)
-			}
-		})
-	}
-}
-
-func TestGetAllSecrets(t *testing.T) {
-	type fields struct {
-		Client       KClient
-		ReviewClient RClient
-		Namespace    string
-	}
-	type args struct {
-		ctx context.Context
-		ref esv1beta1.ExternalSecretFind
-	}
-	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		want    map[string][]byte
-		wantErr bool
-	}{
 		{
-			name: "use regex",
+			name: "label as property with policy fetch",
 			fields: fields{
 				Client: fakeClient{
 					t: t,
 					secretMap: map[string]corev1.Secret{
 						"mysec": {
 							ObjectMeta: metav1.ObjectMeta{
-								Name: "mysec",
-							},
-							Data: map[string][]byte{
-								"token": []byte(`foo`),
-							},
-						},
-						"other": {
-							ObjectMeta: metav1.ObjectMeta{
-								Name: "other",
-							},
-							Data: map[string][]byte{
-								"token": []byte(`bar`),
+								Labels: map[string]string{
+									"app": "nginx",
+								},
 							},
 						},
 					},
 				},
+				Namespace: "default",
 			},
-			args: args{
-				ref: esv1beta1.ExternalSecretFind{
-					Name: &esv1beta1.FindName{
-						RegExp: "other",
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:            "mysec",
+				Property:       "metadata.labels.app",
+				MetadataPolicy: esv1beta1.ExternalSecretMetadataPolicyFetch,
+			},
+			want: []byte(`nginx`),
+		},
+		{
+			name: "missing label with policy fetch",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {},
 					},
 				},
+				Namespace: "default",
 			},
-			want: map[string][]byte{
-				"other": []byte(`{"token":"bar"}`),
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:            "mysec",
+				Property:       "metadata.labels.app",
+				MetadataPolicy: esv1beta1.ExternalSecretMetadataPolicyFetch,
 			},
+			wantErr:   true,
+			wantErrIs: esv1beta1.NoSecretErr,
 		},
 		{
-			name: "use tags/labels",
+			name: "metadata prefix shadows data key with policy none",
 			fields: fields{
 				Client: fakeClient{
 					t: t,
-					expectedListOptions: metav1.ListOptions{
-						LabelSelector: "app=foobar",
-					},
 					secretMap: map[string]corev1.Secret{
 						"mysec": {
 							ObjectMeta: metav1.ObjectMeta{
-								Name: "mysec",
+								Labels: map[string]string{
+									"app": "nginx",
+								},
 							},
 							Data: map[string][]byte{
-								"token": []byte(`foo`),
+								"metadata.labels.app": []byte(`foobar`),
 							},
 						},
-						"other": {
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:            "mysec",
+				Property:       "metadata.labels.app",
+				MetadataPolicy: esv1beta1.ExternalSecretMetadataPolicyNone,
+			},
+			want: []byte(`nginx`),
+		},
+		{
+			name: "metadata policy fetch without property",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							ObjectMeta: metav1.ObjectMeta{
+								Labels: map[string]string{
+									"app": "nginx",
+								},
+								Annotations: map[string]string{
+									"example.com/owner": "team-a",
+								},
+							},
+							Data: map[string][]byte{
+								"token": []byte(`foobar`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:            "mysec",
+				MetadataPolicy: esv1beta1.ExternalSecretMetadataPolicyFetch,
+			},
+			want: []byte(`{"annotations":{"example.com/owner":"team-a"},"labels":{"app":"nginx"}}`),
+		},
+		{
+			name: "managed fields time of key",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							ObjectMeta: metav1.ObjectMeta{
+								ManagedFields: []metav1.ManagedFieldsEntry{
+									{
+										Manager:  "kubectl",
+										Time:     &metav1.Time{Time: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+										FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:data":{".":{},"f:token":{},"f:username":{}}}`)},
+									},
+									{
+										Manager:  "rotator",
+										Time:     &metav1.Time{Time: time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)},
+										FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:data":{"f:token":{}}}`)},
+									},
+								},
+							},
+							Data: map[string][]byte{
+								"token":    []byte(`foobar`),
+								"username": []byte(`foo`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:            "mysec",
+				Property:       "managedFields.username.time",
+				MetadataPolicy: esv1beta1.ExternalSecretMetadataPolicyFetch,
+			},
+			want: []byte(`2022-01-01T00:00:00Z`),
+		},
+		{
+			name: "managed fields time of rotated key",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							ObjectMeta: metav1.ObjectMeta{
+								ManagedFields: []metav1.ManagedFieldsEntry{
+									{
+										Manager:  "kubectl",
+										Time:     &metav1.Time{Time: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+										FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:data":{".":{},"f:token":{},"f:username":{}}}`)},
+									},
+									{
+										Manager:  "rotator",
+										Time:     &metav1.Time{Time: time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)},
+										FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:data":{"f:token":{}}}`)},
+									},
+								},
+							},
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:            "mysec",
+				Property:       "managedFields.token.time",
+				MetadataPolicy: esv1beta1.ExternalSecretMetadataPolicyFetch,
+			},
+			want: []byte(`2022-03-01T12:00:00Z`),
+		},
+		{
+			name: "present empty value",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"token": nil,
+							},
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "token",
+			},
+			want: []byte{},
+		},
+		{
+			name: "absent key",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"token": []byte(`foobar`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "password",
+			},
+			wantErr:   true,
+			wantErrIs: esv1beta1.NoSecretErr,
+		},
+		{
+			name: "label selected secret",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					expectedListOptions: metav1.ListOptions{
+						LabelSelector: "app=db",
+						Limit:         2,
+					},
+					secretMap: map[string]corev1.Secret{
+						"db": {
+							Data: map[string][]byte{
+								"token": []byte(`foobar`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				LabelSelector: "app=db",
+				Property:      "token",
+			},
+			want: []byte(`foobar`),
+		},
+		{
+			name: "label selector without match",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					expectedListOptions: metav1.ListOptions{
+						LabelSelector: "app=db",
+						Limit:         2,
+					},
+					secretMap: map[string]corev1.Secret{},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				LabelSelector: "app=db",
+				Property:      "token",
+			},
+			wantErr:   true,
+			wantErrIs: esv1beta1.NoSecretErr,
+		},
+		{
+			name: "ambiguous label selector",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					expectedListOptions: metav1.ListOptions{
+						LabelSelector: "app=db",
+						Limit:         2,
+					},
+					secretMap: map[string]corev1.Secret{
+						"db": {
+							Data: map[string][]byte{
+								"token": []byte(`foobar`),
+							},
+						},
+						"db-2": {
+							Data: map[string][]byte{
+								"token": []byte(`foo`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				LabelSelector: "app=db",
+				Property:      "token",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid metadata policy",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"token": []byte(`foobar`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:            "mysec",
+				Property:       "token",
+				MetadataPolicy: "Sometimes",
+			},
+			wantErr: true,
+		},
+		{
+			name: "trim trailing newline",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"token": []byte("foobar\n"),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					Trim: esv1beta1.KubernetesTrimNewline,
+				},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "token",
+			},
+			want: []byte(`foobar`),
+		},
+		{
+			name: "trim leaves binary data intact",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"cert": {0xff, 0xfe, 0x0a},
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					Trim: esv1beta1.KubernetesTrimWhitespace,
+				},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "cert",
+			},
+			want: []byte{0xff, 0xfe, 0x0a},
+		},
+		{
+			name: "trim leaves data with control characters intact",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"cert": {0x00, 0x01, 0x0a},
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					Trim: esv1beta1.KubernetesTrimWhitespace,
+				},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "cert",
+			},
+			want: []byte{0x00, 0x01, 0x0a},
+		},
+		{
+			name: "trim trailing whitespace",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"token": []byte("foobar \t\n"),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					Trim: esv1beta1.KubernetesTrimWhitespace,
+				},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "token",
+			},
+			want: []byte(`foobar`),
+		},
+		{
+			name: "value template",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"token": []byte(`foobar`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					ValueTemplate: "postgres://app:{{ .Value }}@db:5432",
+				},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "token",
+			},
+			want: []byte(`postgres://app:foobar@db:5432`),
+		},
+		{
+			name: "value template leaves binary data intact",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"cert": {0xff, 0xfe, 0x0a},
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					ValueTemplate: "postgres://app:{{ .Value }}@db:5432",
+				},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "cert",
+			},
+			want: []byte{0xff, 0xfe, 0x0a},
+		},
+		{
+			name: "exclude keys",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"token":        []byte(`foobar`),
+								"last-applied": []byte(`{}`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					ExcludeKeys: "^last-",
+				},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key: "mysec",
+			},
+			want: []byte(`{"token":"foobar"}`),
+		},
+		{
+			name: "fetch namespace",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "mysec",
+								Namespace: "team-a",
+							},
+							Data: map[string][]byte{
+								"token": []byte(`foobar`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:            "mysec",
+				Property:       "namespace",
+				MetadataPolicy: esv1beta1.ExternalSecretMetadataPolicyFetch,
+			},
+			want: []byte(`team-a`),
+		},
+		{
+			name: "skip terminating secret",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name:              "mysec",
+								DeletionTimestamp: &metav1.Time{Time: time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)},
+								Finalizers:        []string{"example.com/protect"},
+							},
+							Data: map[string][]byte{
+								"token": []byte(`foobar`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					SkipTerminating: true,
+				},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "token",
+			},
+			wantErr:   true,
+			wantErrIs: esv1beta1.NoSecretErr,
+		},
+		{
+			name: "read secret that is not terminating",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name:              "mysec",
+								DeletionTimestamp: nil,
+								Finalizers:        []string{"example.com/protect"},
+							},
+							Data: map[string][]byte{
+								"token": []byte(`foobar`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					SkipTerminating: true,
+				},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "token",
+			},
+			want: []byte(`foobar`),
+		},
+		{
+			name: "fetch deletion timestamp",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name:              "mysec",
+								DeletionTimestamp: &metav1.Time{Time: time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)},
+								Finalizers:        []string{"example.com/protect"},
+							},
+							Data: map[string][]byte{
+								"token": []byte(`foobar`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					SkipTerminating: false,
+				},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:            "mysec",
+				Property:       "deletionTimestamp",
+				MetadataPolicy: esv1beta1.ExternalSecretMetadataPolicyFetch,
+			},
+			want: []byte(`2022-06-01T12:00:00Z`),
+		},
+		{
+			name: "fetch finalizers",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name:              "mysec",
+								DeletionTimestamp: &metav1.Time{Time: time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)},
+								Finalizers:        []string{"example.com/protect"},
+							},
+							Data: map[string][]byte{
+								"token": []byte(`foobar`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					SkipTerminating: false,
+				},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:            "mysec",
+				Property:       "finalizers",
+				MetadataPolicy: esv1beta1.ExternalSecretMetadataPolicyFetch,
+			},
+			want: []byte(`["example.com/protect"]`),
+		},
+		{
+			name: "fetch owner count without owners",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name: "mysec",
+							},
+							Data: map[string][]byte{
+								"token": []byte(`foobar`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:            "mysec",
+				Property:       "ownerCount",
+				MetadataPolicy: esv1beta1.ExternalSecretMetadataPolicyFetch,
+			},
+			want: []byte(`0`),
+		},
+		{
+			name: "fetch owner count with multiple owners",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name: "mysec",
+								OwnerReferences: []metav1.OwnerReference{
+									{APIVersion: "apps/v1", Kind: "Deployment", Name: "api"},
+									{APIVersion: "batch/v1", Kind: "CronJob", Name: "rotate"},
+								},
+							},
+							Data: map[string][]byte{
+								"token": []byte(`foobar`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:            "mysec",
+				Property:       "ownerCount",
+				MetadataPolicy: esv1beta1.ExternalSecretMetadataPolicyFetch,
+			},
+			want: []byte(`2`),
+		},
+		{
+			name: "fetch key count",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"token":    []byte(`foobar`),
+								"username": []byte(`foo`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:            "mysec",
+				Property:       "keyCount",
+				MetadataPolicy: esv1beta1.ExternalSecretMetadataPolicyFetch,
+			},
+			want: []byte(`2`),
+		},
+		{
+			name: "fetch byte size",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"token":    []byte(`foobar`),
+								"username": []byte(`foo`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:            "mysec",
+				Property:       "byteSize",
+				MetadataPolicy: esv1beta1.ExternalSecretMetadataPolicyFetch,
+			},
+			want: []byte(`9`),
+		},
+		{
+			name: "split key into name and property",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"token": []byte(`foobar`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					KeyPropertySeparator: "/",
+				},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key: "mysec/token",
+			},
+			want: []byte(`foobar`),
+		},
+		{
+			name: "dereference secret reference",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"token": []byte(`secretRef: default/target/password`),
+							},
+						},
+						"target": {
+							Data: map[string][]byte{
+								"password": []byte(`foobar`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					Dereference: true,
+				},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "token",
+			},
+			want: []byte(`foobar`),
+		},
+		{
+			name: "dereference rejects self reference",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"token": []byte(`secretRef: default/mysec/token`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					Dereference: true,
+				},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "token",
+			},
+			wantErr: true,
+		},
+		{
+			name: "allowed key",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"token":    []byte(`foobar`),
+								"password": []byte(`s3cr3t`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store:     &esv1beta1.KubernetesProvider{AllowedKeys: "^token$"},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "token",
+			},
+			want: []byte(`foobar`),
+		},
+		{
+			name: "key not in allowed keys",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"token":    []byte(`foobar`),
+								"password": []byte(`s3cr3t`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store:     &esv1beta1.KubernetesProvider{AllowedKeys: "^token$"},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "password",
+			},
+			wantErr:   true,
+			wantErrIs: esv1beta1.NoSecretErr,
+		},
+		{
+			name: "denied key",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"token":    []byte(`foobar`),
+								"password": []byte(`s3cr3t`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store:     &esv1beta1.KubernetesProvider{DeniedKeys: "^pass"},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "password",
+			},
+			wantErr:   true,
+			wantErrIs: esv1beta1.NoSecretErr,
+		},
+		{
+			name: "keys are allowed by default",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"token":    []byte(`foobar`),
+								"password": []byte(`s3cr3t`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store:     &esv1beta1.KubernetesProvider{},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "password",
+			},
+			want: []byte(`s3cr3t`),
+		},
+		{
+			name: "key without separator",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"token": []byte(`foobar`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					KeyPropertySeparator: "/",
+				},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key: "mysec",
+			},
+			want: []byte(`{"token":"foobar"}`),
+		},
+		{
+			name: "expected secret type",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name: "mysec",
+							},
+							Type: corev1.SecretTypeOpaque,
+							Data: map[string][]byte{
+								"token": []byte(`foobar`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					ExpectSecretType: "Opaque",
+				},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "token",
+			},
+			want: []byte(`foobar`),
+		},
+		{
+			name: "unexpected secret type",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name: "mysec",
+							},
+							Type: corev1.SecretTypeOpaque,
+							Data: map[string][]byte{
+								"token": []byte(`foobar`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					ExpectSecretType: "kubernetes.io/tls",
+				},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "token",
+			},
+			wantErr: true,
+		},
+		{
+			name: "key list",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"username": []byte(`foo`),
+								"password": []byte(`bar`),
+								"internal": []byte(`baz`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					DeniedKeys: "",
+				},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "@keys",
+			},
+			want: []byte(`["internal","password","username"]`),
+		},
+		{
+			name: "key list without denied keys",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"username": []byte(`foo`),
+								"password": []byte(`bar`),
+								"internal": []byte(`baz`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					DeniedKeys: "^internal$",
+				},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "@keys",
+			},
+			want: []byte(`["password","username"]`),
+		},
+		{
+			name: "case insensitive key",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"Token": []byte(`foobar`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					CaseInsensitiveKeys: true,
+				},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "token",
+			},
+			want: []byte(`foobar`),
+		},
+		{
+			name: "ambiguous case insensitive key",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"Token": []byte(`foo`),
+								"TOKEN": []byte(`bar`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					CaseInsensitiveKeys: true,
+				},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "token",
+			},
+			wantErr: true,
+		},
+		{
+			name: "numeric leaves without coercion",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"config": []byte(`{"ports":[{"port":8080,"tls":true},{"port":9090,"tls":false}]}`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					CoerceToString: false,
+				},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "config.ports.#.port",
+			},
+			want: []byte(`[8080,9090]`),
+		},
+		{
+			name: "64-bit integer leaf keeps its precision",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"config": []byte(`{"account":{"id":9223372036854775807}}`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "config.account.id",
+			},
+			want: []byte(`9223372036854775807`),
+		},
+		{
+			name: "numeric leaves with coercion",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"config": []byte(`{"ports":[{"port":8080,"tls":true},{"port":9090,"tls":false}]}`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					CoerceToString: true,
+				},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "config.ports.#.port",
+			},
+			want: []byte(`["8080","9090"]`),
+		},
+		{
+			name: "nested leaves with coercion",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"config": []byte(`{"ports":[{"port":8080,"tls":true},{"port":9090,"tls":false}]}`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					CoerceToString: true,
+				},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "config.ports.0",
+			},
+			want: []byte(`{"port":"8080","tls":"true"}`),
+		},
+		{
+			name: "single numeric leaf with coercion",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"config": []byte(`{"ports":[{"port":8080,"tls":true},{"port":9090,"tls":false}]}`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					CoerceToString: true,
+				},
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "config.ports.1.port",
+			},
+			want: []byte(`9090`),
+		},
+		{
+			name: "ini value",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"credentials": []byte("[default]\naws_access_key_id = AKIADEFAULT\n\n; production account\n[profile prod.eu]\naws_access_key_id=AKIAPROD\naws_secret_access_key = secret\n"),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "credentials|ini:default.aws_access_key_id",
+			},
+			want: []byte(`AKIADEFAULT`),
+		},
+		{
+			name: "ini value in section with dots",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"credentials": []byte("[default]\naws_access_key_id = AKIADEFAULT\n\n; production account\n[profile prod.eu]\naws_access_key_id=AKIAPROD\naws_secret_access_key = secret\n"),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "credentials|ini:profile prod.eu.aws_secret_access_key",
+			},
+			want: []byte(`secret`),
+		},
+		{
+			name: "missing ini section",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"credentials": []byte("[default]\naws_access_key_id = AKIADEFAULT\n\n; production account\n[profile prod.eu]\naws_access_key_id=AKIAPROD\naws_secret_access_key = secret\n"),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "credentials|ini:staging.aws_access_key_id",
+			},
+			wantErr:   true,
+			wantErrIs: esv1beta1.NoSecretErr,
+		},
+		{
+			name: "missing ini key",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"credentials": []byte("[default]\naws_access_key_id = AKIADEFAULT\n\n; production account\n[profile prod.eu]\naws_access_key_id=AKIAPROD\naws_secret_access_key = secret\n"),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "credentials|ini:default.aws_session_token",
+			},
+			wantErr:   true,
+			wantErrIs: esv1beta1.NoSecretErr,
+		},
+		{
+			name: "path list",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"config":  []byte(`{"db":{"host":"localhost","ports":[5432,5433]},"app.name":"demo"}`),
+								"token":   []byte(`foobar`),
+								"tls.crt": []byte(`{"ignored":true}`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "@paths",
+			},
+			want: []byte(`["config","config.app\\.name","config.db.host","config.db.ports.0","config.db.ports.1","tls.crt","token"]`),
+		},
+		{
+			name: "json path with multiple results",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"users": []byte(`[{"name":"alice","role":"admin"},{"name":"bob"}]`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "users.#.name",
+			},
+			want: []byte(`["alice","bob"]`),
+		},
+		{
+			name: "json path without matches",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"users": []byte(`[{"name":"alice","role":"admin"},{"name":"bob"}]`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "users.#.email",
+			},
+			want: []byte(`[]`),
+		},
+		{
+			name: "json path with a single result",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"users": []byte(`[{"name":"alice","role":"admin"},{"name":"bob"}]`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "users.0.name",
+			},
+			want: []byte(`alice`),
+		},
+		{
+			name: "projection selects and renames fields",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"config":   []byte(`{"db":{"host":"db.local","port":5432},"debug":true}`),
+								"password": []byte(`foobar`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: `@project:{"DB_HOST":"config.db.host","DB_PORT":"config.db.port","DB_PASSWORD":"password"}`,
+			},
+			want: []byte(`{"DB_HOST":"db.local","DB_PASSWORD":"foobar","DB_PORT":"5432"}`),
+		},
+		{
+			name: "projection with missing source",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"password": []byte(`foobar`),
+							},
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: `@project:{"DB_HOST":"config.db.host"}`,
+			},
+			wantErr:   true,
+			wantErrIs: esv1beta1.NoSecretErr,
+		},
+		{
+			name: "nil data returns an empty secret",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: nil,
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key: "mysec",
+			},
+			want: []byte(`{}`),
+		},
+		{
+			name: "nil data property does not exist",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: nil,
+						},
+					},
+				},
+				Namespace: "default",
+			},
+			ref: esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "token",
+			},
+			wantErr:   true,
+			wantErrIs: esv1beta1.NoSecretErr,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ProviderKubernetes{
+				Client:       tt.fields.Client,
+				ReviewClient: tt.fields.ReviewClient,
+				Namespace:    tt.fields.Namespace,
+				store:        tt.fields.store,
+			}
+			if tt.fields.store != nil {
+				if err := p.setTransforms(tt.fields.store); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := p.GetSecret(context.Background(), tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ProviderKubernetes.GetSecret() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErrIs != nil && !errors.Is(err, tt.wantErrIs) {
+				t.Errorf("ProviderKubernetes.GetSecret() error = %v, want %v", err, tt.wantErrIs)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ProviderKubernetes.GetSecret() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	type fields struct {
+		Client       KClient
+		ReviewClient RClient
+		Namespace    string
+	}
+	type args struct {
+		store     esv1beta1.GenericStore
+		kube      kclient.Client
+		namespace string
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:   "invalid store",
+			fields: fields{},
+			args: args{
+				store: &esv1beta1.ClusterSecretStore{
+					TypeMeta: metav1.TypeMeta{
+						Kind: esv1beta1.ClusterSecretStoreKind,
+					},
+					Spec: esv1beta1.SecretStoreSpec{
+						Provider: &esv1beta1.SecretStoreProvider{},
+					},
+				},
+				kube: fclient.NewClientBuilder().Build(),
+			},
+			wantErr: true,
+		},
+		{
+			name:   "invalid value template",
+			fields: fields{},
+			args: args{
+				store: &esv1beta1.SecretStore{
+					TypeMeta: metav1.TypeMeta{
+						Kind: esv1beta1.SecretStoreKind,
+					},
+					Spec: esv1beta1.SecretStoreSpec{
+						Provider: &esv1beta1.SecretStoreProvider{
+							Kubernetes: &esv1beta1.KubernetesProvider{
+								ValueTemplate: "{{ .Value ",
+							},
+						},
+					},
+				},
+				kube: fclient.NewClientBuilder().Build(),
+			},
+			wantErr: true,
+		},
+		{
+			name:   "test referent auth return",
+			fields: fields{},
+			args: args{
+				store: &esv1beta1.ClusterSecretStore{
+					TypeMeta: metav1.TypeMeta{
+						Kind: esv1beta1.ClusterSecretStoreKind,
+					},
+					Spec: esv1beta1.SecretStoreSpec{
+						Provider: &esv1beta1.SecretStoreProvider{
+							Kubernetes: &esv1beta1.KubernetesProvider{
+								Server: esv1beta1.KubernetesServer{
+									CABundle: []byte(testCertificate),
+								},
+								Auth: esv1beta1.KubernetesAuth{
+									Token: &esv1beta1.TokenAuth{
+										BearerToken: v1.SecretKeySelector{
+											Name: "foo",
+											Key:  "token",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+				namespace: "",
+				kube:      fclient.NewClientBuilder().Build(),
+			},
+			want: true,
+		},
+		{
+			name:   "auth fail results in error",
+			fields: fields{},
+			args: args{
+				store: &esv1beta1.ClusterSecretStore{
+					TypeMeta: metav1.TypeMeta{
+						Kind: esv1beta1.ClusterSecretStoreKind,
+					},
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "auth-fail",
+					},
+					Spec: esv1beta1.SecretStoreSpec{
+						Provider: &esv1beta1.SecretStoreProvider{
+							Kubernetes: &esv1beta1.KubernetesProvider{
+								Server: esv1beta1.KubernetesServer{
+									CABundle: []byte(testCertificate),
+								},
+								RemoteNamespace: "remote",
+								Auth: esv1beta1.KubernetesAuth{
+									Token: &esv1beta1.TokenAuth{
+										BearerToken: v1.SecretKeySelector{
+											Name:      "foo",
+											Namespace: pointer.String("default"),
+											Key:       "token",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+				namespace: "foobarothernamespace",
+				kube:      fclient.NewClientBuilder().Build(),
+			},
+			wantErr: true,
+		},
+		{
+			name:   "test auth",
+			fields: fields{},
+			args: args{
+				store: &esv1beta1.ClusterSecretStore{
+					TypeMeta: metav1.TypeMeta{
+						Kind: esv1beta1.ClusterSecretStoreKind,
+					},
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "auth-ok",
+					},
+					Spec: esv1beta1.SecretStoreSpec{
+						Provider: &esv1beta1.SecretStoreProvider{
+							Kubernetes: &esv1beta1.KubernetesProvider{
+								Server: esv1beta1.KubernetesServer{
+									CABundle: []byte(testCertificate),
+								},
+								RemoteNamespace: "remote",
+								Auth: esv1beta1.KubernetesAuth{
+									Token: &esv1beta1.TokenAuth{
+										BearerToken: v1.SecretKeySelector{
+											Name:      "foo",
+											Namespace: pointer.String("default"),
+											Key:       "token",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+				namespace: "foobarothernamespace",
+				kube: fclient.NewClientBuilder().WithObjects(&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "foo",
+						Namespace: "default",
+					},
+					Data: map[string][]byte{
+						"token": []byte("1234"),
+					},
+				}).Build(),
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ProviderKubernetes{
+				Client:       tt.fields.Client,
+				ReviewClient: tt.fields.ReviewClient,
+				Namespace:    tt.fields.Namespace,
+			}
+			got, err := p.NewClient(context.Background(), tt.args.store, tt.args.kube, tt.args.namespace)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ProviderKubernetes.NewClient() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.want {
+				assert.NotNil(t, got)
+			} else {
+				assert.Nil(t, got)
+			}
+		})
+	}
+}
+
+// TestNewClientIsolatesStores runs concurrent NewClient calls for stores
+// with different filters on one provider, run it with -race.
+func TestNewClientIsolatesStores(t *testing.T) {
+	newStore := func(name, exclude string) *esv1beta1.ClusterSecretStore {
+		return &esv1beta1.ClusterSecretStore{
+			TypeMeta: metav1.TypeMeta{
+				Kind: esv1beta1.ClusterSecretStoreKind,
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+			Spec: esv1beta1.SecretStoreSpec{
+				Provider: &esv1beta1.SecretStoreProvider{
+					Kubernetes: &esv1beta1.KubernetesProvider{
+						ExcludeKeys:  exclude,
+						RequireLabel: name + "=true",
+						Auth: esv1beta1.KubernetesAuth{
+							Token: &esv1beta1.TokenAuth{
+								BearerToken: v1.SecretKeySelector{
+									Name: "foo",
+									Key:  "token",
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+	stores := []*esv1beta1.ClusterSecretStore{
+		newStore("store-a", "^a-"),
+		newStore("store-b", "^b-"),
+	}
+	p := &ProviderKubernetes{}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		for _, store := range stores {
+			wg.Add(1)
+			go func(store *esv1beta1.ClusterSecretStore) {
+				defer wg.Done()
+				got, err := p.NewClient(context.Background(), store, fclient.NewClientBuilder().Build(), "")
+				if !assert.NoError(t, err) {
+					return
+				}
+				c := got.(*ProviderKubernetes)
+				assert.Equal(t, store.Spec.Provider.Kubernetes.ExcludeKeys, c.excludeKeys.String())
+				assert.Equal(t, store.Spec.Provider.Kubernetes.RequireLabel, c.requireLabel.String())
+				assert.Equal(t, store.Name, c.storeName)
+			}(store)
+		}
+	}
+	wg.Wait()
+	assert.Nil(t, p.store)
+	assert.Nil(t, p.excludeKeys)
+}
+
+func TestGetAllSecrets(t *testing.T) {
+	type fields struct {
+		Client       KClient
+		ReviewClient RClient
+		Namespace    string
+		store        *esv1beta1.KubernetesProvider
+	}
+	type args struct {
+		ref esv1beta1.ExternalSecretFind
+	}
+	tests := []struct {
+		name       string
+		fields     fields
+		args       args
+		want       map[string][]byte
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name: "use regex",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name: "mysec",
+							},
+							Data: map[string][]byte{
+								"token": []byte(`foo`),
+							},
+						},
+						"other": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name: "other",
+							},
+							Data: map[string][]byte{
+								"token": []byte(`bar`),
+							},
+						},
+					},
+				},
+			},
+			args: args{
+				ref: esv1beta1.ExternalSecretFind{
+					Name: &esv1beta1.FindName{
+						RegExp: "other",
+					},
+				},
+			},
+			want: map[string][]byte{
+				"other": []byte(`{"token":"bar"}`),
+			},
+		},
+		{
+			name: "empty secrets are skipped by default",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name: "mysec",
+							},
+							Data: map[string][]byte{
+								"token": []byte(`foo`),
+							},
+						},
+						"placeholder": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name: "placeholder",
+							},
+						},
+					},
+				},
+			},
+			args: args{
+				ref: esv1beta1.ExternalSecretFind{
+					Name: &esv1beta1.FindName{
+						RegExp: ".*",
+					},
+				},
+			},
+			want: map[string][]byte{
+				"mysec": []byte(`{"token":"foo"}`),
+			},
+		},
+		{
+			name: "include empty secrets",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name: "mysec",
+							},
+							Data: map[string][]byte{
+								"token": []byte(`foo`),
+							},
+						},
+						"placeholder": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name: "placeholder",
+							},
+						},
+					},
+				},
+			},
+			args: args{
+				ref: esv1beta1.ExternalSecretFind{
+					Name: &esv1beta1.FindName{
+						RegExp: ".*",
+					},
+					IncludeEmpty: true,
+				},
+			},
+			want: map[string][]byte{
+				"mysec":       []byte(`{"token":"foo"}`),
+				"placeholder": []byte(`{}`),
+			},
+		},
+		{
+			name: "use tags/labels",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					expectedListOptions: metav1.ListOptions{
+						LabelSelector: "app=foobar",
+					},
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name: "mysec",
+							},
+							Data: map[string][]byte{
+								"token": []byte(`foo`),
+							},
+						},
+						"other": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name: "other",
+							},
+							Data: map[string][]byte{
+								"token": []byte(`bar`),
+							},
+						},
+					},
+				},
+			},
+			args: args{
+				ref: esv1beta1.ExternalSecretFind{
+					Tags: map[string]string{
+						"app": "foobar",
+					},
+				},
+			},
+			want: map[string][]byte{
+				"mysec": []byte(`{"token":"foo"}`),
+				"other": []byte(`{"token":"bar"}`),
+			},
+		},
+		{
+			name: "strip prefix",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"team-a-db": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name: "team-a-db",
+							},
+							Data: map[string][]byte{
+								"token": []byte(`foo`),
+							},
+						},
+						"team-b-db": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name: "team-b-db",
+							},
+							Data: map[string][]byte{
+								"token": []byte(`bar`),
+							},
+						},
+					},
+				},
+			},
+			args: args{
+				ref: esv1beta1.ExternalSecretFind{
+					Name: &esv1beta1.FindName{
+						RegExp: "team-a-.*",
+					},
+					StripPrefix: "team-a-",
+				},
+			},
+			want: map[string][]byte{
+				"db": []byte(`{"token":"foo"}`),
+			},
+		},
+		{
+			name: "strip suffix",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					expectedListOptions: metav1.ListOptions{
+						LabelSelector: "app=foobar",
+					},
+					secretMap: map[string]corev1.Secret{
+						"db-creds": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name: "db-creds",
+							},
+							Data: map[string][]byte{
+								"token": []byte(`foo`),
+							},
+						},
+					},
+				},
+			},
+			args: args{
+				ref: esv1beta1.ExternalSecretFind{
+					Tags: map[string]string{
+						"app": "foobar",
+					},
+					StripSuffix: "-creds",
+				},
+			},
+			want: map[string][]byte{
+				"db": []byte(`{"token":"foo"}`),
+			},
+		},
+		{
+			name: "strip prefix collision",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"team-a-db": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name: "team-a-db",
+							},
+							Data: map[string][]byte{
+								"token": []byte(`foo`),
+							},
+						},
+						"db": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name: "db",
+							},
+							Data: map[string][]byte{
+								"token": []byte(`bar`),
+							},
+						},
+					},
+				},
+			},
+			args: args{
+				ref: esv1beta1.ExternalSecretFind{
+					Name: &esv1beta1.FindName{
+						RegExp: "db$",
+					},
+					StripPrefix: "team-a-",
+				},
+			},
+			wantErr:    true,
+			wantErrMsg: "secret name collision after stripping: db and team-a-db both result in db",
+		},
+		{
+			name: "no match with empty result policy Empty",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					expectedListOptions: metav1.ListOptions{
+						LabelSelector: "app=missing",
+					},
+				},
+				store: &esv1beta1.KubernetesProvider{
+					EmptyResultPolicy: esv1beta1.KubernetesEmptyResultEmpty,
+				},
+			},
+			args: args{
+				ref: esv1beta1.ExternalSecretFind{
+					Tags: map[string]string{
+						"app": "missing",
+					},
+				},
+			},
+			want: map[string][]byte{},
+		},
+		{
+			name: "no match with empty result policy Error",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					expectedListOptions: metav1.ListOptions{
+						LabelSelector: "app=missing",
+					},
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					EmptyResultPolicy: esv1beta1.KubernetesEmptyResultError,
+				},
+			},
+			args: args{
+				ref: esv1beta1.ExternalSecretFind{
+					Tags: map[string]string{
+						"app": "missing",
+					},
+				},
+			},
+			wantErr:    true,
+			wantErrMsg: "no secrets matched in namespace default",
+		},
+		{
+			name: "only keys modified since",
+			fields: fields{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name: "mysec",
+								ManagedFields: []metav1.ManagedFieldsEntry{
+									{
+										Manager:  "kubectl",
+										Time:     &metav1.Time{Time: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+										FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:data":{".":{},"f:token":{},"f:username":{}}}`)},
+									},
+									{
+										Manager:  "rotator",
+										Time:     &metav1.Time{Time: time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)},
+										FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:data":{"f:token":{}}}`)},
+									},
+								},
+							},
+							Data: map[string][]byte{
+								"token":    []byte(`foo`),
+								"username": []byte(`bar`),
+							},
+						},
+						"old": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name: "old",
+								ManagedFields: []metav1.ManagedFieldsEntry{
+									{
+										Manager:  "kubectl",
+										Time:     &metav1.Time{Time: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+										FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:data":{".":{},"f:token":{}}}`)},
+									},
+								},
+							},
+							Data: map[string][]byte{
+								"token": []byte(`baz`),
+							},
+						},
+						"unmanaged": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name:              "unmanaged",
+								CreationTimestamp: metav1.NewTime(time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)),
+							},
+							Data: map[string][]byte{
+								"token": []byte(`qux`),
+							},
+						},
+					},
+				},
+			},
+			args: args{
+				ref: esv1beta1.ExternalSecretFind{
+					Name: &esv1beta1.FindName{
+						RegExp: ".*",
+					},
+					ModifiedSince: &metav1.Time{Time: time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)},
+				},
+			},
+			want: map[string][]byte{
+				"mysec":     []byte(`{"token":"foo"}`),
+				"unmanaged": []byte(`{"token":"qux"}`),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ProviderKubernetes{
+				Client:       tt.fields.Client,
+				ReviewClient: tt.fields.ReviewClient,
+				Namespace:    tt.fields.Namespace,
+				store:        tt.fields.store,
+			}
+			got, err := p.GetAllSecrets(context.Background(), tt.args.ref)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ProviderKubernetes.GetAllSecrets() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErrMsg != "" && err.Error() != tt.wantErrMsg {
+				t.Errorf("ProviderKubernetes.GetAllSecrets() error = %v, want %v", err, tt.wantErrMsg)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ProviderKubernetes.GetAllSecrets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// pagedClient returns one secret per page.
+type pagedClient struct {
+	KClient
+	secrets []corev1.Secret
+}
+
+func (pc pagedClient) List(ctx context.Context, opts metav1.ListOptions) (*corev1.SecretList, error) {
+	i := 0
+	if opts.Continue != "" {
+		i, _ = strconv.Atoi(opts.Continue)
+	}
+	list := &corev1.SecretList{Items: pc.secrets[i : i+1]}
+	if i+1 < len(pc.secrets) {
+		list.Continue = strconv.Itoa(i + 1)
+	}
+	return list, nil
+}
+
+func TestGetAllSecretsStream(t *testing.T) {
+	p := &ProviderKubernetes{
+		Client: pagedClient{
+			secrets: []corev1.Secret{
+				{ObjectMeta: metav1.ObjectMeta{Name: "a"}, Data: map[string][]byte{"token": []byte(`a`)}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "b"}, Data: map[string][]byte{"token": []byte(`b`)}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "c"}, Data: map[string][]byte{"token": []byte(`c`)}},
+			},
+		},
+	}
+	ref := esv1beta1.ExternalSecretFind{
+		Name: &esv1beta1.FindName{
+			RegExp: ".*",
+		},
+	}
+
+	var keys []string
+	err := p.GetAllSecretsStream(context.Background(), ref, func(key string, value []byte) error {
+		keys = append(keys, key)
+		assert.Equal(t, `{"token":"`+key+`"}`, string(value))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, keys)
+
+	keys = nil
+	errStop := errors.New("stop")
+	err = p.GetAllSecretsStream(context.Background(), ref, func(key string, value []byte) error {
+		keys = append(keys, key)
+		if key == "b" {
+			return errStop
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, errStop)
+	assert.Equal(t, []string{"a", "b"}, keys)
+
+	keys = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = p.GetAllSecretsStream(ctx, ref, func(key string, value []byte) error {
+		keys = append(keys, key)
+		cancel()
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []string{"a"}, keys)
+}
+
+func TestGetAllSecretsMaxResults(t *testing.T) {
+	var messages []string
+	ErrorHook = func(store esv1beta1.GenericStore, reason, message string) {
+		assert.Equal(t, reasonFindTruncated, reason)
+		messages = append(messages, message)
+	}
+	defer func() { ErrorHook = nil }()
+	p := &ProviderKubernetes{
+		Client: pagedClient{
+			secrets: []corev1.Secret{
+				{ObjectMeta: metav1.ObjectMeta{Name: "a"}, Data: map[string][]byte{"token": []byte(`a`)}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "b"}, Data: map[string][]byte{"token": []byte(`b`)}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "c"}, Data: map[string][]byte{"token": []byte(`c`)}},
+			},
+		},
+		genericStore: &esv1beta1.SecretStore{
+			TypeMeta: metav1.TypeMeta{
+				Kind: esv1beta1.SecretStoreKind,
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "max-results",
+				Namespace: "default",
+			},
+		},
+	}
+	tests := []struct {
+		name         string
+		maxResults   int
+		want         map[string][]byte
+		wantMessages []string
+	}{
+		{
+			name:       "truncated",
+			maxResults: 2,
+			want: map[string][]byte{
+				"a": []byte(`{"token":"a"}`),
+				"b": []byte(`{"token":"b"}`),
+			},
+			wantMessages: []string{"find result truncated, more than maxResults 2 secrets match"},
+		},
+		{
+			name:       "under the cap",
+			maxResults: 5,
+			want: map[string][]byte{
+				"a": []byte(`{"token":"a"}`),
+				"b": []byte(`{"token":"b"}`),
+				"c": []byte(`{"token":"c"}`),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages = nil
+			got, err := p.GetAllSecrets(context.Background(), esv1beta1.ExternalSecretFind{
+				Name: &esv1beta1.FindName{
+					RegExp: ".*",
+				},
+				MaxResults: tt.maxResults,
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantMessages, messages)
+		})
+	}
+}
+
+func TestGetSecretMapEnvCompatibleKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string][]byte
+		want    map[string][]byte
+		wantErr bool
+	}{
+		{
+			name: "dashed key is normalized",
+			data: map[string][]byte{
+				"db-password": []byte(`foobar`),
+				"1st.user":    []byte(`foo`),
+			},
+			want: map[string][]byte{
+				"DB_PASSWORD": []byte(`foobar`),
+				"_1ST_USER":   []byte(`foo`),
+			},
+		},
+		{
+			name: "collision after normalization",
+			data: map[string][]byte{
+				"db-password": []byte(`foobar`),
+				"db_password": []byte(`foo`),
+			},
+			wantErr: true,
+		},
+		{
+			name: "nil data",
+			data: nil,
+			want: map[string][]byte{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ProviderKubernetes{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: tt.data,
+						},
+					},
+				},
+				store: &esv1beta1.KubernetesProvider{
+					EnvCompatibleKeys: true,
+				},
+			}
+			got, err := p.GetSecretMap(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{Key: "mysec"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ProviderKubernetes.GetSecretMap() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ProviderKubernetes.GetSecretMap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetSecretMapSanitizeKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     map[string][]byte
+		sanitize bool
+		want     map[string][]byte
+		wantErr  bool
+	}{
+		{
+			name: "illegal key is rejected",
+			data: map[string][]byte{
+				"..data": []byte(`foo`),
+			},
+			wantErr: true,
+		},
+		{
+			name: "illegal keys are sanitized",
+			data: map[string][]byte{
+				"..data":     []byte(`foo`),
+				"db/pass":    []byte(`bar`),
+				"valid.name": []byte(`baz`),
+			},
+			sanitize: true,
+			want: map[string][]byte{
+				"__data":     []byte(`foo`),
+				"db_pass":    []byte(`bar`),
+				"valid.name": []byte(`baz`),
+			},
+		},
+		{
+			name: "collision after sanitizing",
+			data: map[string][]byte{
+				"db/pass": []byte(`foo`),
+				"db_pass": []byte(`bar`),
+			},
+			sanitize: true,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ProviderKubernetes{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: tt.data,
+						},
+					},
+				},
+				store: &esv1beta1.KubernetesProvider{
+					SanitizeKeys: tt.sanitize,
+				},
+			}
+			got, err := p.GetSecretMap(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{Key: "mysec"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ProviderKubernetes.GetSecretMap() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ProviderKubernetes.GetSecretMap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetSecretMapCaseInsensitiveKeys(t *testing.T) {
+	p := &ProviderKubernetes{
+		Client: fakeClient{
+			t: t,
+			secretMap: map[string]corev1.Secret{
+				"mysec": {
+					Data: map[string][]byte{
+						"Token": []byte(`foo`),
+						"token": []byte(`bar`),
+					},
+				},
+			},
+		},
+		store: &esv1beta1.KubernetesProvider{
+			CaseInsensitiveKeys: true,
+		},
+	}
+	_, err := p.GetSecretMap(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{Key: "mysec"})
+	assert.EqualError(t, err, "ambiguous keys: Token and token differ only by case")
+}
+
+func TestMaxKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxKeys int
+		wantErr string
+	}{
+		{
+			name:    "under limit",
+			maxKeys: 2,
+		},
+		{
+			name:    "over limit",
+			maxKeys: 1,
+			wantErr: "secret has 2 keys, more than the maximum of 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ProviderKubernetes{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"token":    []byte(`foobar`),
+								"username": []byte(`foo`),
+							},
+						},
+					},
+				},
+				store: &esv1beta1.KubernetesProvider{
+					MaxKeys: tt.maxKeys,
+				},
+			}
+			ref := esv1beta1.ExternalSecretDataRemoteRef{Key: "mysec"}
+			_, mapErr := p.GetSecretMap(context.Background(), ref)
+			_, secretErr := p.GetSecret(context.Background(), ref)
+			if tt.wantErr == "" {
+				assert.NoError(t, mapErr)
+				assert.NoError(t, secretErr)
+				return
+			}
+			assert.EqualError(t, mapErr, tt.wantErr)
+			assert.EqualError(t, secretErr, tt.wantErr)
+		})
+	}
+}
+
+func TestGetSecretRenderYAML(t *testing.T) {
+	p := &ProviderKubernetes{
+		Client: fakeClient{
+			t: t,
+			secretMap: map[string]corev1.Secret{
+				"mysec": {
+					Data: map[string][]byte{
+						"username": []byte(`foo`),
+						"password": []byte(`bar`),
+						"cert":     {0xff, 0x00},
+					},
+				},
+			},
+		},
+		store: &esv1beta1.KubernetesProvider{
+			RenderFormat: esv1beta1.KubernetesRenderYAML,
+		},
+	}
+	want := "cert: !!binary /wA=\npassword: bar\nusername: foo\n"
+	for i := 0; i < 10; i++ {
+		got, err := p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{Key: "mysec"})
+		assert.NoError(t, err)
+		assert.Equal(t, want, string(got))
+	}
+}
+
+type fakeAccessReviewClient struct {
+	t       *testing.T
+	allowed bool
+}
+
+func (fk fakeAccessReviewClient) Create(ctx context.Context, review *authv1.SelfSubjectAccessReview, opts metav1.CreateOptions) (*authv1.SelfSubjectAccessReview, error) {
+	assert.Equal(fk.t, &authv1.ResourceAttributes{
+		Namespace: "default",
+		Verb:      "get",
+		Resource:  "secrets",
+		Name:      "mysec",
+	}, review.Spec.ResourceAttributes)
+	review.Status.Allowed = fk.allowed
+	if !fk.allowed {
+		review.Status.Reason = "denied by policy"
+	}
+	return review, nil
+}
+
+// fakeNamedAccessReviewClient denies access to the listed secrets.
+type fakeNamedAccessReviewClient struct {
+	denied map[string]bool
+}
+
+func (fn fakeNamedAccessReviewClient) Create(ctx context.Context, review *authv1.SelfSubjectAccessReview, opts metav1.CreateOptions) (*authv1.SelfSubjectAccessReview, error) {
+	review.Status.Allowed = !fn.denied[review.Spec.ResourceAttributes.Name]
+	if !review.Status.Allowed {
+		review.Status.Reason = "denied by policy"
+	}
+	return review, nil
+}
+
+func TestDereferenceReadGuards(t *testing.T) {
+	exported := map[string]string{"eso.io/export": "true"}
+	ptrSecret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "ptr", Labels: exported},
+		Type:       corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			"token": []byte(`secretRef: default/hidden/password`),
+		},
+	}
+	tests := []struct {
+		name      string
+		target    corev1.Secret
+		store     *esv1beta1.KubernetesProvider
+		wantErr   string
+		wantErrIs error
+	}{
+		{
+			name: "unlabeled target with requireLabel",
+			target: corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "hidden"},
+				Data:       map[string][]byte{"password": []byte(`s3cr3t`)},
+			},
+			store: &esv1beta1.KubernetesProvider{
+				Dereference:  true,
+				RequireLabel: "eso.io/export=true",
+			},
+			wantErrIs: esv1beta1.NoSecretErr,
+		},
+		{
+			name: "terminating target with skipTerminating",
+			target: corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "hidden",
+					Labels:            exported,
+					DeletionTimestamp: &metav1.Time{Time: time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)},
+				},
+				Data: map[string][]byte{"password": []byte(`s3cr3t`)},
+			},
+			store: &esv1beta1.KubernetesProvider{
+				Dereference:     true,
+				SkipTerminating: true,
+			},
+			wantErrIs: esv1beta1.NoSecretErr,
+		},
+		{
+			name: "denied target with checkAccessOnRead",
+			target: corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "hidden", Labels: exported},
+				Data:       map[string][]byte{"password": []byte(`s3cr3t`)},
+			},
+			store: &esv1beta1.KubernetesProvider{
+				Dereference:       true,
+				CheckAccessOnRead: true,
+			},
+			wantErr: "client is not allowed to get secret hidden: denied by policy",
+		},
+		{
+			name: "target of unexpected type",
+			target: corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "hidden", Labels: exported},
+				Type:       corev1.SecretTypeTLS,
+				Data:       map[string][]byte{"password": []byte(`s3cr3t`)},
+			},
+			store: &esv1beta1.KubernetesProvider{
+				Dereference:      true,
+				ExpectSecretType: string(corev1.SecretTypeOpaque),
+			},
+			wantErr: "secret hidden has type kubernetes.io/tls, expected Opaque",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ProviderKubernetes{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"ptr":    ptrSecret,
+						"hidden": tt.target,
+					},
+				},
+				AccessReviewClient: fakeNamedAccessReviewClient{
+					denied: map[string]bool{"hidden": true},
+				},
+				Namespace: "default",
+			}
+			assert.NoError(t, p.setTransforms(tt.store))
+			p.store = tt.store
+			got, err := p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "ptr",
+				Property: "token",
+			})
+			assert.Nil(t, got)
+			if tt.wantErrIs != nil {
+				assert.ErrorIs(t, err, tt.wantErrIs)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestCheckAccessOnRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed bool
+		wantErr string
+	}{
+		{
+			name:    "allowed",
+			allowed: true,
+		},
+		{
+			name:    "denied",
+			wantErr: "client is not allowed to get secret mysec: denied by policy",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ProviderKubernetes{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
 							ObjectMeta: metav1.ObjectMeta{
-								Name: "other",
+								Name: "mysec",
+							},
+							Data: map[string][]byte{
+								"token": []byte(`foobar`),
+							},
+						},
+					},
+				},
+				AccessReviewClient: fakeAccessReviewClient{
+					t:       t,
+					allowed: tt.allowed,
+				},
+				Namespace: "default",
+				store: &esv1beta1.KubernetesProvider{
+					CheckAccessOnRead: true,
+				},
+			}
+			got, err := p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{Key: "mysec", Property: "token"})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, []byte(`foobar`), got)
+		})
+	}
+}
+
+// blockingClient counts Gets and blocks them until release is closed.
+type blockingClient struct {
+	KClient
+	calls   *int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (bc blockingClient) Get(ctx context.Context, name string, opts metav1.GetOptions) (*corev1.Secret, error) {
+	if atomic.AddInt32(bc.calls, 1) == 1 {
+		close(bc.started)
+	}
+	<-bc.release
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Data: map[string][]byte{
+			"token": []byte(`foobar`),
+		},
+	}, nil
+}
+
+// coalescedWaiters counts the goroutines that wait for an in-flight coalesced Get.
+func coalescedWaiters() int {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	waiters := 0
+	for _, stack := range strings.Split(string(buf), "\n\n") {
+		if strings.Contains(stack, "singleflight.(*Group).Do") && strings.Contains(stack, "sync.(*WaitGroup).Wait") {
+			waiters++
+		}
+	}
+	return waiters
+}
+
+func TestCoalesceReads(t *testing.T) {
+	var calls int32
+	client := blockingClient{
+		calls:   &calls,
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	p := &ProviderKubernetes{
+		Client:    client,
+		Namespace: "default",
+		store: &esv1beta1.KubernetesProvider{
+			CoalesceReads: true,
+		},
+	}
+	const readers = 20
+	var wg sync.WaitGroup
+	results := make([][]byte, readers)
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			val, err := p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{Key: "mysec", Property: "token"})
+			assert.NoError(t, err)
+			results[i] = val
+		}(i)
+	}
+	<-client.started
+	// release the Get once all other readers joined it
+	assert.Eventually(t, func() bool {
+		return coalescedWaiters() == readers-1
+	}, 10*time.Second, time.Millisecond)
+	close(client.release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	for _, val := range results {
+		assert.Equal(t, []byte(`foobar`), val)
+	}
+}
+
+func TestVerifyHash(t *testing.T) {
+	const foobarHash = "c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2"
+	tests := []struct {
+		name        string
+		value       string
+		annotations map[string]string
+		wantErr     string
+	}{
+		{
+			name:  "matching hash",
+			value: "foobar",
+			annotations: map[string]string{
+				"hash.external-secrets.io/token": foobarHash,
+			},
+		},
+		{
+			name:  "tampered value",
+			value: "tampered",
+			annotations: map[string]string{
+				"hash.external-secrets.io/token": foobarHash,
+			},
+			wantErr: "hash of key token in secret mysec does not match its annotation",
+		},
+		{
+			name:    "missing annotation",
+			value:   "foobar",
+			wantErr: "secret mysec has no hash annotation for key token",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ProviderKubernetes{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							ObjectMeta: metav1.ObjectMeta{
+								Name:        "mysec",
+								Annotations: tt.annotations,
+							},
+							Data: map[string][]byte{
+								"token": []byte(tt.value),
+							},
+						},
+					},
+				},
+				store: &esv1beta1.KubernetesProvider{
+					VerifyHash: true,
+				},
+			}
+			got, err := p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{Key: "mysec", Property: "token"})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, []byte(tt.value), got)
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	// 0xfb 0xff encodes to characters that differ between standard and URL-safe base64
+	value := []byte{0xfb, 0xff}
+	tests := []struct {
+		encoding esv1beta1.KubernetesEncoding
+		want     string
+	}{
+		{encoding: esv1beta1.KubernetesEncodingNone, want: string(value)},
+		{encoding: esv1beta1.KubernetesEncodingBase64, want: "+/8="},
+		{encoding: esv1beta1.KubernetesEncodingBase64URL, want: "-_8="},
+		{encoding: esv1beta1.KubernetesEncodingBase64URLNoPad, want: "-_8"},
+		{encoding: esv1beta1.KubernetesEncodingHex, want: "fbff"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.encoding), func(t *testing.T) {
+			p := &ProviderKubernetes{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"token": value,
+							},
+						},
+					},
+				},
+				store: &esv1beta1.KubernetesProvider{
+					Encode: tt.encoding,
+				},
+			}
+			got, err := p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{Key: "mysec", Property: "token"})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestEncodeAfterProperty(t *testing.T) {
+	p := &ProviderKubernetes{
+		Client: fakeClient{
+			t: t,
+			secretMap: map[string]corev1.Secret{
+				"mysec": {
+					Data: map[string][]byte{
+						"config": []byte(`{"db":{"user":"admin"}}`),
+					},
+				},
+			},
+		},
+		store: &esv1beta1.KubernetesProvider{
+			Encode: esv1beta1.KubernetesEncodingBase64,
+		},
+	}
+	got, err := p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{
+		Key:          "mysec",
+		Property:     "config.db.user",
+		ValuePattern: "^admin$",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "YWRtaW4=", string(got))
+}
+
+func TestEncodeGzip(t *testing.T) {
+	p := &ProviderKubernetes{
+		Client: fakeClient{
+			t: t,
+			secretMap: map[string]corev1.Secret{
+				"mysec": {
+					Data: map[string][]byte{
+						"token": []byte(`foobar`),
+					},
+				},
+			},
+		},
+		store: &esv1beta1.KubernetesProvider{
+			Encode: esv1beta1.KubernetesEncodingGzip,
+		},
+	}
+	got, err := p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{Key: "mysec", Property: "token"})
+	assert.NoError(t, err)
+	zr, err := gzip.NewReader(bytes.NewReader(got))
+	assert.NoError(t, err)
+	plain, err := io.ReadAll(zr)
+	assert.NoError(t, err)
+	assert.Equal(t, "foobar", string(plain))
+}
+
+func TestEncodeGzipSerialized(t *testing.T) {
+	p := &ProviderKubernetes{
+		Client: fakeClient{
+			t: t,
+			secretMap: map[string]corev1.Secret{
+				"mysec": {
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "mysec",
+					},
+					Data: map[string][]byte{
+						"token": []byte(`foobar`),
+					},
+				},
+			},
+		},
+		store: &esv1beta1.KubernetesProvider{
+			Encode: esv1beta1.KubernetesEncodingGzip,
+		},
+	}
+	ctx := context.Background()
+	_, err := p.GetSecret(ctx, esv1beta1.ExternalSecretDataRemoteRef{Key: "mysec"})
+	assert.ErrorIs(t, err, errGzipSerialized)
+	_, err = p.GetAllSecrets(ctx, esv1beta1.ExternalSecretFind{Name: &esv1beta1.FindName{RegExp: "mysec"}})
+	assert.ErrorIs(t, err, errGzipSerialized)
+
+	// computed values are based on the plain data
+	got, err := p.GetSecret(ctx, esv1beta1.ExternalSecretDataRemoteRef{
+		Key:            "mysec",
+		Property:       "byteSize",
+		MetadataPolicy: esv1beta1.ExternalSecretMetadataPolicyFetch,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "6", string(got))
+
+	// every value of a secret map is compressed on its own
+	data, err := p.GetSecretMap(ctx, esv1beta1.ExternalSecretDataRemoteRef{Key: "mysec"})
+	assert.NoError(t, err)
+	zr, err := gzip.NewReader(bytes.NewReader(data["token"]))
+	assert.NoError(t, err)
+	plain, err := io.ReadAll(zr)
+	assert.NoError(t, err)
+	assert.Equal(t, "foobar", string(plain))
+}
+
+func TestMinRefreshInterval(t *testing.T) {
+	tests := []struct {
+		name  string
+		floor *metav1.Duration
+		want  time.Duration
+	}{
+		{
+			name: "not set",
+			want: 0,
+		},
+		{
+			name:  "five minutes",
+			floor: &metav1.Duration{Duration: 5 * time.Minute},
+			want:  5 * time.Minute,
+		},
+		{
+			name:  "negative is ignored",
+			floor: &metav1.Duration{Duration: -time.Minute},
+			want:  0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &esv1beta1.SecretStore{
+				Spec: esv1beta1.SecretStoreSpec{
+					Provider: &esv1beta1.SecretStoreProvider{
+						Kubernetes: &esv1beta1.KubernetesProvider{
+							MinRefreshInterval: tt.floor,
+						},
+					},
+				},
+			}
+			assert.Equal(t, tt.want, (&ProviderKubernetes{}).MinRefreshInterval(store))
+		})
+	}
+}
+
+func TestDryRunValidate(t *testing.T) {
+	p := &ProviderKubernetes{
+		Client: fakeClient{
+			t: t,
+			secretMap: map[string]corev1.Secret{
+				"mysec": {
+					Data: map[string][]byte{
+						"token": []byte(`foobar`),
+					},
+				},
+			},
+		},
+	}
+	n, err := p.DryRunValidate(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{Key: "mysec", Property: "token"})
+	assert.NoError(t, err)
+	assert.Equal(t, 6, n)
+
+	n, err = p.DryRunValidate(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{Key: "mysec", Property: "password"})
+	assert.ErrorIs(t, err, esv1beta1.NoSecretErr)
+	assert.Equal(t, 0, n)
+
+	_, err = p.DryRunValidate(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{Key: "other"})
+	assert.EqualError(t, err, errSomethingWentWrong)
+}
+
+func TestGetSecretChecksum(t *testing.T) {
+	secrets := map[string]corev1.Secret{
+		"mysec": {
+			Data: map[string][]byte{
+				"token":    []byte(`foobar`),
+				"username": []byte(`foo`),
+			},
+		},
+	}
+	p := &ProviderKubernetes{
+		Client: fakeClient{
+			t:         t,
+			secretMap: secrets,
+		},
+	}
+	ref := esv1beta1.ExternalSecretDataRemoteRef{
+		Key:            "mysec",
+		Property:       "checksum",
+		MetadataPolicy: esv1beta1.ExternalSecretMetadataPolicyFetch,
+	}
+	first, err := p.GetSecret(context.Background(), ref)
+	assert.NoError(t, err)
+	assert.Len(t, first, 64)
+	for i := 0; i < 10; i++ {
+		got, err := p.GetSecret(context.Background(), ref)
+		assert.NoError(t, err)
+		assert.Equal(t, first, got)
+	}
+
+	secrets["mysec"].Data["token"] = []byte(`changed`)
+	changed, err := p.GetSecret(context.Background(), ref)
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, changed)
+}
+
+func TestGetSecretDeduplicate(t *testing.T) {
+	p := &ProviderKubernetes{
+		Client: fakeClient{
+			t: t,
+			secretMap: map[string]corev1.Secret{
+				"mysec": {
+					Data: map[string][]byte{
+						"db-password":    []byte(`shared`),
+						"api-password":   []byte(`shared`),
+						"cache-password": []byte(`shared`),
+						"token":          []byte(`foo`),
+						"user":           []byte(`admin`),
+						"owner":          []byte(`admin`),
+						"unique":         []byte(`bar`),
+					},
+				},
+			},
+		},
+	}
+	got, err := p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{
+		Key:            "mysec",
+		Property:       "deduplicate",
+		MetadataPolicy: esv1beta1.ExternalSecretMetadataPolicyFetch,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `[["api-password","cache-password","db-password"],["owner","user"]]`, string(got))
+	assert.NotContains(t, string(got), "shared")
+	assert.NotContains(t, string(got), "admin")
+}
+
+func TestGetSecretBinaryKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		store *esv1beta1.KubernetesProvider
+		want  string
+	}{
+		{
+			name: "auto",
+			want: `["cert.der","control","keystore"]`,
+		},
+		{
+			name: "encoded values are not inspected",
+			store: &esv1beta1.KubernetesProvider{
+				Encode: esv1beta1.KubernetesEncodingBase64,
+			},
+			want: `["cert.der","control","keystore"]`,
+		},
+		{
+			name: "binary value encoding",
+			store: &esv1beta1.KubernetesProvider{
+				ValueEncoding: esv1beta1.KubernetesValueEncodingBinary,
+			},
+			want: `["cert.der","control","keystore","token","unicode"]`,
+		},
+		{
+			name: "utf8 value encoding",
+			store: &esv1beta1.KubernetesProvider{
+				ValueEncoding: esv1beta1.KubernetesValueEncodingUTF8,
+			},
+			want: `[]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ProviderKubernetes{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"keystore": {0xfe, 0xed, 0xfe, 0xed},
+								"token":    []byte(`foobar`),
+								"cert.der": {0x30, 0x82, 0xff},
+								"unicode":  []byte(`grüße`),
+								"control":  []byte("a\x00b"),
+							},
+						},
+					},
+				},
+				store: tt.store,
+			}
+			got, err := p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{
+				Key:            "mysec",
+				Property:       "binaryKeys",
+				MetadataPolicy: esv1beta1.ExternalSecretMetadataPolicyFetch,
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+type fakeSAClient struct {
+	serviceAccounts map[string]corev1.ServiceAccount
+}
+
+func (fs fakeSAClient) Get(ctx context.Context, name string, opts metav1.GetOptions) (*corev1.ServiceAccount, error) {
+	sa, ok := fs.serviceAccounts[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(corev1.Resource("serviceaccounts"), name)
+	}
+	return &sa, nil
+}
+
+func TestGetSecretServiceAccountToken(t *testing.T) {
+	p := &ProviderKubernetes{
+		Client: fakeClient{
+			t: t,
+			secretMap: map[string]corev1.Secret{
+				"builder-dockercfg": {
+					ObjectMeta: metav1.ObjectMeta{Name: "builder-dockercfg"},
+					Type:       corev1.SecretTypeDockercfg,
+					Data: map[string][]byte{
+						".dockercfg": []byte(`{}`),
+					},
+				},
+				"builder-token-abcde": {
+					ObjectMeta: metav1.ObjectMeta{Name: "builder-token-abcde"},
+					Type:       corev1.SecretTypeServiceAccountToken,
+					Data: map[string][]byte{
+						"token":  []byte(`eyJhbGciOi`),
+						"ca.crt": []byte(`cert`),
+					},
+				},
+			},
+		},
+		ServiceAccountClient: fakeSAClient{
+			serviceAccounts: map[string]corev1.ServiceAccount{
+				"builder": {
+					ObjectMeta: metav1.ObjectMeta{Name: "builder"},
+					Secrets: []corev1.ObjectReference{
+						{Name: "builder-dockercfg"},
+						{Name: "builder-token-abcde"},
+					},
+				},
+				"tokenless": {
+					ObjectMeta: metav1.ObjectMeta{Name: "tokenless"},
+				},
+			},
+		},
+	}
+	got, err := p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{Key: "sa:builder"})
+	assert.NoError(t, err)
+	assert.Equal(t, "eyJhbGciOi", string(got))
+
+	got, err = p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{Key: "sa:builder", Property: "ca.crt"})
+	assert.NoError(t, err)
+	assert.Equal(t, "cert", string(got))
+
+	_, err = p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{Key: "sa:tokenless"})
+	assert.ErrorIs(t, err, esv1beta1.NoSecretErr)
+
+	_, err = p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{Key: "sa:missing"})
+	assert.ErrorIs(t, err, esv1beta1.NoSecretErr)
+}
+
+// fakeErrClient returns the configured error when a secret is read.
+type fakeErrClient struct {
+	fakeClient
+	errs map[string]error
+}
+
+func (fk fakeErrClient) Get(ctx context.Context, name string, opts metav1.GetOptions) (*corev1.Secret, error) {
+	if err, ok := fk.errs[name]; ok {
+		return nil, err
+	}
+	return fk.fakeClient.Get(ctx, name, opts)
+}
+
+func TestGetSecretServiceAccountTokenErrors(t *testing.T) {
+	p := &ProviderKubernetes{
+		Client: fakeErrClient{
+			fakeClient: fakeClient{
+				t: t,
+				secretMap: map[string]corev1.Secret{
+					"builder-token-abcde": {
+						ObjectMeta: metav1.ObjectMeta{Name: "builder-token-abcde"},
+						Type:       corev1.SecretTypeServiceAccountToken,
+						Data: map[string][]byte{
+							"token": []byte(`eyJhbGciOi`),
+						},
+					},
+				},
+			},
+			errs: map[string]error{
+				"builder-token-old": apierrors.NewNotFound(corev1.Resource("secrets"), "builder-token-old"),
+				"restricted-token":  apierrors.NewForbidden(corev1.Resource("secrets"), "restricted-token", errors.New("denied")),
+			},
+		},
+		ServiceAccountClient: fakeSAClient{
+			serviceAccounts: map[string]corev1.ServiceAccount{
+				"builder": {
+					ObjectMeta: metav1.ObjectMeta{Name: "builder"},
+					Secrets: []corev1.ObjectReference{
+						{Name: "builder-token-old"},
+						{Name: "builder-token-abcde"},
+					},
+				},
+				"restricted": {
+					ObjectMeta: metav1.ObjectMeta{Name: "restricted"},
+					Secrets: []corev1.ObjectReference{
+						{Name: "restricted-token"},
+						{Name: "builder-token-abcde"},
+					},
+				},
+			},
+		},
+	}
+	// deleted secrets are skipped
+	got, err := p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{Key: "sa:builder"})
+	assert.NoError(t, err)
+	assert.Equal(t, "eyJhbGciOi", string(got))
+
+	// other errors are returned
+	_, err = p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{Key: "sa:restricted"})
+	assert.True(t, apierrors.IsForbidden(err), "unexpected error: %v", err)
+}
+
+func TestSATokenShortcut(t *testing.T) {
+	secrets := map[string]corev1.Secret{
+		"builder-token": {
+			Type: corev1.SecretTypeServiceAccountToken,
+			Data: map[string][]byte{
+				"token": []byte(`eyJhbGciOi`),
+			},
+		},
+		"opaque": {
+			Type: corev1.SecretTypeOpaque,
+			Data: map[string][]byte{
+				"token": []byte(`foobar`),
+			},
+		},
+	}
+	tests := []struct {
+		name     string
+		key      string
+		shortcut bool
+		want     string
+	}{
+		{
+			name:     "shortcut on",
+			key:      "builder-token",
+			shortcut: true,
+			want:     `eyJhbGciOi`,
+		},
+		{
+			name: "shortcut off",
+			key:  "builder-token",
+			want: `{"token":"eyJhbGciOi"}`,
+		},
+		{
+			name:     "shortcut on ignores other types",
+			key:      "opaque",
+			shortcut: true,
+			want:     `{"token":"foobar"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ProviderKubernetes{
+				Client: fakeClient{
+					t:         t,
+					secretMap: secrets,
+				},
+				store: &esv1beta1.KubernetesProvider{
+					SATokenShortcut: tt.shortcut,
+				},
+			}
+			got, err := p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{Key: tt.key})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestGetSecretManifest(t *testing.T) {
+	p := &ProviderKubernetes{
+		Client: fakeClient{
+			t: t,
+			secretMap: map[string]corev1.Secret{
+				"mysec": {
+					Data: map[string][]byte{
+						"tls.crt": []byte(`certificate`),
+						"tls.key": []byte(`key`),
+						"empty":   {},
+					},
+				},
+			},
+		},
+	}
+	got, err := p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{
+		Key:            "mysec",
+		Property:       "@manifest",
+		MetadataPolicy: esv1beta1.ExternalSecretMetadataPolicyFetch,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"empty":0,"tls.crt":11,"tls.key":3}`, string(got))
+}
+
+func TestGetSecretTLS(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string][]byte
+		want    string
+		wantErr string
+	}{
+		{
+			name: "complete tls secret",
+			data: map[string][]byte{
+				"tls.crt": []byte("cert"),
+				"tls.key": []byte("key"),
+				"ca.crt":  []byte("ca"),
+			},
+			want: `{"cert":"cert","key":"key","ca":"ca"}`,
+		},
+		{
+			name: "tls secret without ca",
+			data: map[string][]byte{
+				"tls.crt": []byte("cert"),
+				"tls.key": []byte("key"),
+			},
+			want: `{"cert":"cert","key":"key"}`,
+		},
+		{
+			name: "incomplete tls secret",
+			data: map[string][]byte{
+				"tls.crt": []byte("cert"),
+			},
+			wantErr: "secret is missing the tls keys tls.key",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ProviderKubernetes{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Type: corev1.SecretTypeTLS,
+							Data: tt.data,
+						},
+					},
+				},
+			}
+			got, err := p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: "@tls",
+			})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestGetSecretValuePattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "matching value",
+			pattern: "^ghp_",
+			want:    "ghp_foobar",
+		},
+		{
+			name:    "value does not match",
+			pattern: "^glpat-",
+			wantErr: "value of key mysec does not match valuePattern ^glpat-",
+		},
+		{
+			name:    "invalid pattern",
+			pattern: "(",
+			wantErr: "unable to parse valuePattern: error parsing regexp: missing closing ): `(`",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ProviderKubernetes{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"token": []byte("ghp_foobar"),
 							},
+						},
+					},
+				},
+			}
+			got, err := p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{
+				Key:          "mysec",
+				Property:     "token",
+				ValuePattern: tt.pattern,
+			})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				assert.Nil(t, got)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestGetSecretDefaultProperty(t *testing.T) {
+	tests := []struct {
+		name     string
+		property string
+		want     string
+	}{
+		{
+			name: "default property is applied",
+			want: "payload",
+		},
+		{
+			name:     "explicit property overrides the default",
+			property: "other",
+			want:     "foobar",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ProviderKubernetes{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
 							Data: map[string][]byte{
-								"token": []byte(`bar`),
+								"value": []byte("payload"),
+								"other": []byte("foobar"),
 							},
 						},
 					},
 				},
-			},
-			args: args{
-				ref: esv1beta1.ExternalSecretFind{
-					Tags: map[string]string{
-						"app": "foobar",
+				store: &esv1beta1.KubernetesProvider{
+					DefaultProperty: "value",
+				},
+			}
+			got, err := p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: tt.property,
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestGetSecretRequireLabel(t *testing.T) {
+	p := &ProviderKubernetes{
+		Client: fakeClient{
+			t: t,
+			secretMap: map[string]corev1.Secret{
+				"exported": {
+					ObjectMeta: metav1.ObjectMeta{
+						Name:   "exported",
+						Labels: map[string]string{"eso.io/export": "true"},
 					},
+					Data: map[string][]byte{"token": []byte("foobar")},
+				},
+				"internal": {
+					ObjectMeta: metav1.ObjectMeta{Name: "internal"},
+					Data:       map[string][]byte{"token": []byte("s3cr3t")},
 				},
 			},
-			want: map[string][]byte{
-				"mysec": []byte(`{"token":"foo"}`),
-				"other": []byte(`{"token":"bar"}`),
-			},
+		},
+	}
+	store := &esv1beta1.KubernetesProvider{RequireLabel: "eso.io/export=true"}
+	assert.NoError(t, p.setTransforms(store))
+	p.store = store
+
+	got, err := p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{
+		Key:      "exported",
+		Property: "token",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "foobar", string(got))
+
+	got, err = p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{
+		Key:      "internal",
+		Property: "token",
+	})
+	assert.ErrorIs(t, err, esv1beta1.NoSecretErr)
+	assert.Nil(t, got)
+}
+
+// newTarArchive packs the files into a tar archive, gzip compressed if compress is set.
+func newTarArchive(t *testing.T, files map[string]string, compress bool) []byte {
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var zw *gzip.Writer
+	if compress {
+		zw = gzip.NewWriter(&buf)
+		w = zw
+	}
+	tw := tar.NewWriter(w)
+	for name, content := range files {
+		assert.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0o600,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+	if zw != nil {
+		assert.NoError(t, zw.Close())
+	}
+	return buf.Bytes()
+}
+
+func TestGetSecretTarEntry(t *testing.T) {
+	files := map[string]string{
+		"./certs/ca.crt": "ca",
+		"config.yaml":    "debug: true",
+	}
+	tests := []struct {
+		name      string
+		archive   []byte
+		property  string
+		want      string
+		wantErrIs error
+	}{
+		{
+			name:     "present entry",
+			archive:  newTarArchive(t, files, false),
+			property: "bundle|tar:config.yaml",
+			want:     "debug: true",
+		},
+		{
+			name:     "present entry with leading dot",
+			archive:  newTarArchive(t, files, false),
+			property: "bundle|tar:certs/ca.crt",
+			want:     "ca",
+		},
+		{
+			name:     "present entry in gzip archive",
+			archive:  newTarArchive(t, files, true),
+			property: "bundle|tar:config.yaml",
+			want:     "debug: true",
+		},
+		{
+			name:      "absent entry",
+			archive:   newTarArchive(t, files, false),
+			property:  "bundle|tar:secrets.env",
+			wantErrIs: esv1beta1.NoSecretErr,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			p := &ProviderKubernetes{
-				Client:       tt.fields.Client,
-				ReviewClient: tt.fields.ReviewClient,
-				Namespace:    tt.fields.Namespace,
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {
+							Data: map[string][]byte{
+								"bundle": tt.archive,
+							},
+						},
+					},
+				},
 			}
-			got, err := p.GetAllSecrets(tt.args.ctx, tt.args.ref)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("ProviderKubernetes.GetAllSecrets() error = %v, wantErr %v", err, tt.wantErr)
+			got, err := p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{
+				Key:      "mysec",
+				Property: tt.property,
+			})
+			if tt.wantErrIs != nil {
+				assert.ErrorIs(t, err, tt.wantErrIs)
 				return
 			}
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("ProviderKubernetes.GetAllSecrets() = %v, want %v", got, tt.want)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestGetSecretValueEncoding(t *testing.T) {
+	data := map[string][]byte{
+		// "café\n" in latin1
+		"name":  {0x63, 0x61, 0x66, 0xe9, 0x0a},
+		"token": []byte("foobar\n"),
+	}
+	tests := []struct {
+		name     string
+		encoding esv1beta1.KubernetesValueEncoding
+		want     string
+	}{
+		{
+			name:     "auto detects latin1 values as binary",
+			encoding: esv1beta1.KubernetesValueEncodingAuto,
+			want:     "{\"name\":\"caf\ufffd\\n\",\"token\":\"foobar\"}",
+		},
+		{
+			name:     "latin1 values are converted to utf-8",
+			encoding: esv1beta1.KubernetesValueEncodingLatin1,
+			want:     `{"name":"café","token":"foobar"}`,
+		},
+		{
+			name:     "binary values are not trimmed",
+			encoding: esv1beta1.KubernetesValueEncodingBinary,
+			want:     "{\"name\":\"caf\ufffd\\n\",\"token\":\"foobar\\n\"}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ProviderKubernetes{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": {Data: data},
+					},
+				},
+				store: &esv1beta1.KubernetesProvider{
+					Trim:          esv1beta1.KubernetesTrimNewline,
+					ValueEncoding: tt.encoding,
+				},
 			}
+			got, err := p.GetSecret(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{Key: "mysec"})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
 		})
 	}
 }
+
+func TestNewRestConfigCABundle(t *testing.T) {
+	ca1, pem1 := newTestCA(t, "ca-1")
+	ca2, pem2 := newTestCA(t, "ca-2")
+	client := BaseClient{
+		store: &esv1beta1.KubernetesProvider{
+			Server: esv1beta1.KubernetesServer{
+				URL: "https://my.remote.cluster",
+			},
+		},
+		CA: append(pem1, pem2...),
+	}
+	tlsConfig, err := rest.TLSConfigFor(client.newRestConfig())
+	assert.NoError(t, err)
+	for _, ca := range []*x509.Certificate{ca1, ca2} {
+		_, err := ca.Verify(x509.VerifyOptions{Roots: tlsConfig.RootCAs})
+		assert.NoError(t, err, "%s should be trusted", ca.Subject.CommonName)
+	}
+}
+
+func TestNewRestConfigTLSServerName(t *testing.T) {
+	client := BaseClient{
+		store: &esv1beta1.KubernetesProvider{
+			Server: esv1beta1.KubernetesServer{
+				URL:           "https://10.0.0.1",
+				TLSServerName: "my.remote.cluster",
+			},
+		},
+	}
+	cfg := client.newRestConfig()
+	assert.Equal(t, "https://10.0.0.1", cfg.Host)
+	assert.Equal(t, "my.remote.cluster", cfg.TLSClientConfig.ServerName)
+}
+
+func TestDialContext(t *testing.T) {
+	var dialed string
+	DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = address
+		return nil, errors.New("tunnel unavailable")
+	}
+	defer func() { DialContext = nil }()
+	client := BaseClient{
+		store: &esv1beta1.KubernetesProvider{
+			Server: esv1beta1.KubernetesServer{
+				URL: "https://127.0.0.1:16443",
+			},
+		},
+	}
+	clientSet, err := clientSetFor(client.newRestConfig(), nil)
+	assert.NoError(t, err)
+	_, err = clientSet.CoreV1().Secrets("default").Get(context.Background(), "mysec", metav1.GetOptions{})
+	assert.ErrorContains(t, err, "tunnel unavailable")
+	assert.Equal(t, "127.0.0.1:16443", dialed)
+}
+
+func TestContentNegotiation(t *testing.T) {
+	var accept string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept")
+		// answer with JSON like a server without protobuf support
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"kind":"Secret","apiVersion":"v1","metadata":{"name":"mysec"},"data":{"token":"Zm9vYmFy"}}`))
+	}))
+	defer srv.Close()
+	client := BaseClient{
+		store: &esv1beta1.KubernetesProvider{
+			Server: esv1beta1.KubernetesServer{
+				URL: srv.URL,
+			},
+		},
+		CA: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}),
+	}
+	cfg := client.newRestConfig()
+	assert.Equal(t, "application/vnd.kubernetes.protobuf", cfg.ContentType)
+	clientSet, err := clientSetFor(cfg, nil)
+	assert.NoError(t, err)
+	secret, err := clientSet.CoreV1().Secrets("default").Get(context.Background(), "mysec", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "application/vnd.kubernetes.protobuf,application/json", accept)
+	assert.Equal(t, []byte(`foobar`), secret.Data["token"])
+}
+
+func newTestCA(t *testing.T, name string) (*x509.Certificate, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
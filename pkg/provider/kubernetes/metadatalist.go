@@ -0,0 +1,96 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+
+	"github.com/external-secrets/external-secrets/pkg/find"
+)
+
+// partialObjectMetadataList asks the API server for the metadata of the
+// listed objects only, as the metadata client of client-go does.
+const partialObjectMetadataList = "application/json;as=PartialObjectMetadataList;g=meta.k8s.io;v=v1"
+
+// secretMetadataClient lists the metadata of the secrets in a namespace.
+// It shares the REST client, and with it the transport, of the clientset.
+type secretMetadataClient struct {
+	client    rest.Interface
+	namespace string
+}
+
+func (c secretMetadataClient) List(ctx context.Context, opts metav1.ListOptions) (*metav1.PartialObjectMetadataList, error) {
+	raw, err := c.client.Get().
+		Namespace(c.namespace).
+		Resource("secrets").
+		VersionedParams(&opts, metav1.ParameterCodec).
+		SetHeader("Accept", partialObjectMetadataList).
+		Do(ctx).
+		Raw()
+	if err != nil {
+		return nil, err
+	}
+	list := &metav1.PartialObjectMetadataList{}
+	if err := json.Unmarshal(raw, list); err != nil {
+		return nil, fmt.Errorf("unable to decode secret metadata: %w", err)
+	}
+	return list, nil
+}
+
+// streamByMetadata lists only the metadata of the secrets and reads
+// the full objects of the matching secrets, see listMetadataOnly.
+func (p *ProviderKubernetes) streamByMetadata(ctx context.Context, opts metav1.ListOptions, matcher *find.Matcher, keys *keyTracker, fn func(key string, value []byte) error) error {
+	for {
+		// fn may be slow, stop before requesting the next page once ctx is done
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		list, err := p.MetadataClient.List(ctx, opts)
+		if err != nil {
+			return fmt.Errorf("unable to list secret metadata: %w", err)
+		}
+		for i := range list.Items {
+			if !p.findMatches(&corev1.Secret{ObjectMeta: list.Items[i].ObjectMeta}, matcher) {
+				continue
+			}
+			secret, err := p.getByName(ctx, list.Items[i].Name)
+			if apierrors.IsNotFound(err) {
+				// deleted since it was listed
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("unable to get secret %s: %w", list.Items[i].Name, err)
+			}
+			// the labels may have changed since the secret was listed
+			if !p.findMatches(secret, matcher) {
+				continue
+			}
+			if err := p.streamSecret(secret, keys, fn); err != nil {
+				return err
+			}
+		}
+		if list.Continue == "" {
+			return nil
+		}
+		opts.Continue = list.Continue
+	}
+}
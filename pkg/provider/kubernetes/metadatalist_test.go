@@ -0,0 +1,118 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	esv1beta1 "github.com/external-secrets/external-secrets/apis/externalsecrets/v1beta1"
+)
+
+type fakeMetadataClient struct {
+	names []string
+}
+
+func (fm fakeMetadataClient) List(ctx context.Context, opts metav1.ListOptions) (*metav1.PartialObjectMetadataList, error) {
+	list := &metav1.PartialObjectMetadataList{}
+	for _, name := range fm.names {
+		list.Items = append(list.Items, metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	return list, nil
+}
+
+// recordingClient records the names of the secrets that were read
+// and fails the test if the full secrets are listed.
+type recordingClient struct {
+	fakeClient
+	gets *[]string
+}
+
+func (rc recordingClient) Get(ctx context.Context, name string, opts metav1.GetOptions) (*corev1.Secret, error) {
+	*rc.gets = append(*rc.gets, name)
+	return rc.fakeClient.Get(ctx, name, opts)
+}
+
+func (rc recordingClient) List(ctx context.Context, opts metav1.ListOptions) (*corev1.SecretList, error) {
+	rc.t.Error("unexpected list of full secrets")
+	return &corev1.SecretList{}, nil
+}
+
+func TestGetAllSecretsListMetadataOnly(t *testing.T) {
+	secrets := map[string]corev1.Secret{}
+	for _, name := range []string{"team-a-db", "team-a-api", "team-b-db", "other"} {
+		secrets[name] = corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Data:       map[string][]byte{"token": []byte(name)},
+		}
+	}
+	var gets []string
+	p := &ProviderKubernetes{
+		Client: recordingClient{
+			fakeClient: fakeClient{t: t, secretMap: secrets},
+			gets:       &gets,
+		},
+		MetadataClient: fakeMetadataClient{
+			names: []string{"other", "team-a-api", "team-a-db", "team-b-db"},
+		},
+		store: &esv1beta1.KubernetesProvider{
+			ListMetadataOnly: true,
+		},
+	}
+	got, err := p.GetAllSecrets(context.Background(), esv1beta1.ExternalSecretFind{
+		Name: &esv1beta1.FindName{RegExp: "^team-a-"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"team-a-api": []byte(`{"token":"team-a-api"}`),
+		"team-a-db":  []byte(`{"token":"team-a-db"}`),
+	}, got)
+	assert.Equal(t, []string{"team-a-api", "team-a-db"}, gets)
+}
+
+func TestSecretMetadataClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/namespaces/default/secrets", r.URL.Path)
+		assert.Equal(t, "app=db", r.URL.Query().Get("labelSelector"))
+		assert.Equal(t, partialObjectMetadataList, r.Header.Get("Accept"))
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(metav1.PartialObjectMetadataList{
+			ListMeta: metav1.ListMeta{Continue: "next"},
+			Items: []metav1.PartialObjectMetadata{
+				{ObjectMeta: metav1.ObjectMeta{Name: "mysec", Labels: map[string]string{"app": "db"}}},
+			},
+		})
+	}))
+	defer srv.Close()
+	cs, err := kubernetes.NewForConfig(&rest.Config{Host: srv.URL})
+	assert.NoError(t, err)
+	c := secretMetadataClient{client: cs.CoreV1().RESTClient(), namespace: "default"}
+
+	got, err := c.List(context.Background(), metav1.ListOptions{LabelSelector: "app=db"})
+	assert.NoError(t, err)
+	assert.Equal(t, "next", got.Continue)
+	assert.Len(t, got.Items, 1)
+	assert.Equal(t, "mysec", got.Items[0].Name)
+	assert.Equal(t, map[string]string{"app": "db"}, got.Items[0].Labels)
+}
@@ -0,0 +1,61 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	kubernetesProviderSubsystem = "kubernetes_provider"
+	secretAgeKey                = "secret_age_seconds"
+
+	// lastUpdatedAnnotation may be set on a remote secret to an RFC3339
+	// timestamp of its last change. It takes precedence over the creationTimestamp.
+	lastUpdatedAnnotation = "external-secrets.io/last-updated"
+)
+
+var secretAge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Subsystem: kubernetesProviderSubsystem,
+	Name:      secretAgeKey,
+	Help:      "Seconds since the remote secret was last changed",
+}, []string{"store_kind", "store", "namespace", "key"})
+
+// updateSecretAge records the time since the secret was last changed.
+func (p *ProviderKubernetes) updateSecretAge(secret *corev1.Secret) {
+	lastUpdate := secret.CreationTimestamp.Time
+	if val, ok := secret.Annotations[lastUpdatedAnnotation]; ok {
+		if t, err := time.Parse(time.RFC3339, val); err == nil {
+			lastUpdate = t
+		}
+	}
+	if lastUpdate.IsZero() || p.clock == nil {
+		return
+	}
+	secretAge.With(prometheus.Labels{
+		"store_kind": p.storeKind,
+		"store":      p.storeName,
+		"namespace":  p.storeNamespace,
+		"key":        secret.Name,
+	}).Set(p.clock.Since(lastUpdate).Seconds())
+}
+
+func init() {
+	metrics.Registry.MustRegister(secretAge)
+}
@@ -0,0 +1,104 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	esv1beta1 "github.com/external-secrets/external-secrets/apis/externalsecrets/v1beta1"
+)
+
+func TestSecretAge(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		secret corev1.Secret
+		want   float64
+	}{
+		{
+			name: "creation timestamp",
+			secret: corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "mysec",
+					CreationTimestamp: metav1.NewTime(now.Add(-time.Hour)),
+				},
+			},
+			want: 3600,
+		},
+		{
+			name: "last updated annotation",
+			secret: corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "mysec",
+					CreationTimestamp: metav1.NewTime(now.Add(-time.Hour)),
+					Annotations: map[string]string{
+						lastUpdatedAnnotation: now.Add(-time.Minute).Format(time.RFC3339),
+					},
+				},
+			},
+			want: 60,
+		},
+		{
+			name: "invalid annotation falls back to creation timestamp",
+			secret: corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "mysec",
+					CreationTimestamp: metav1.NewTime(now.Add(-time.Hour)),
+					Annotations: map[string]string{
+						lastUpdatedAnnotation: "yesterday",
+					},
+				},
+			},
+			want: 3600,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secretAge.Reset()
+			p := &ProviderKubernetes{
+				Client: fakeClient{
+					t: t,
+					secretMap: map[string]corev1.Secret{
+						"mysec": tt.secret,
+					},
+				},
+				storeKind:      esv1beta1.SecretStoreKind,
+				storeName:      "example",
+				storeNamespace: "default",
+				clock:          clocktesting.NewFakePassiveClock(now),
+			}
+			if _, err := p.GetSecretMap(context.Background(), esv1beta1.ExternalSecretDataRemoteRef{Key: "mysec"}); err != nil {
+				t.Fatal(err)
+			}
+			got := testutil.ToFloat64(secretAge.With(prometheus.Labels{
+				"store_kind": esv1beta1.SecretStoreKind,
+				"store":      "example",
+				"namespace":  "default",
+				"key":        "mysec",
+			}))
+			if got != tt.want {
+				t.Errorf("secret age = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	authv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ListAccessibleNamespaces returns the sorted namespaces in which the store
+// identity may list secrets, e.g. for UI tooling. Every namespace is checked
+// with a SelfSubjectAccessReview. If the identity may not list namespaces,
+// only the remoteNamespace of the store is checked.
+func (p *ProviderKubernetes) ListAccessibleNamespaces(ctx context.Context) ([]string, error) {
+	candidates, err := p.listNamespaces(ctx)
+	if apierrors.IsForbidden(err) {
+		candidates, err = []string{p.Namespace}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	accessible := []string{}
+	for _, ns := range candidates {
+		allowed, err := p.canListSecrets(ctx, ns)
+		if err != nil {
+			return nil, err
+		}
+		if allowed {
+			accessible = append(accessible, ns)
+		}
+	}
+	sort.Strings(accessible)
+	return accessible, nil
+}
+
+// listNamespaces returns the names of all namespaces, page by page.
+func (p *ProviderKubernetes) listNamespaces(ctx context.Context) ([]string, error) {
+	var names []string
+	opts := metav1.ListOptions{Limit: listPageSize}
+	for {
+		namespaces, err := p.NamespaceClient.List(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list namespaces: %w", err)
+		}
+		for i := range namespaces.Items {
+			names = append(names, namespaces.Items[i].Name)
+		}
+		if namespaces.Continue == "" {
+			return names, nil
+		}
+		opts.Continue = namespaces.Continue
+	}
+}
+
+// canListSecrets reports whether the store identity may list secrets in the namespace.
+func (p *ProviderKubernetes) canListSecrets(ctx context.Context, namespace string) (bool, error) {
+	review, err := p.AccessReviewClient.Create(ctx, &authv1.SelfSubjectAccessReview{
+		Spec: authv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      "list",
+				Resource:  "secrets",
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("could not verify if client is allowed to list secrets in namespace %s: %w", namespace, err)
+	}
+	return review.Status.Allowed, nil
+}
@@ -0,0 +1,118 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	authv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type fakeNamespaceClient struct {
+	namespaces []string
+	err        error
+}
+
+func (fn fakeNamespaceClient) List(ctx context.Context, opts metav1.ListOptions) (*corev1.NamespaceList, error) {
+	if fn.err != nil {
+		return nil, fn.err
+	}
+	list := &corev1.NamespaceList{}
+	for _, name := range fn.namespaces {
+		list.Items = append(list.Items, corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	return list, nil
+}
+
+// fakeNamespaceReviewClient allows listing secrets in the granted namespaces.
+type fakeNamespaceReviewClient struct {
+	t       *testing.T
+	granted map[string]bool
+}
+
+func (fr fakeNamespaceReviewClient) Create(ctx context.Context, review *authv1.SelfSubjectAccessReview, opts metav1.CreateOptions) (*authv1.SelfSubjectAccessReview, error) {
+	attrs := review.Spec.ResourceAttributes
+	assert.Equal(fr.t, "list", attrs.Verb)
+	assert.Equal(fr.t, "secrets", attrs.Resource)
+	review.Status.Allowed = fr.granted[attrs.Namespace]
+	return review, nil
+}
+
+func TestListAccessibleNamespaces(t *testing.T) {
+	granted := map[string]bool{
+		"team-b":  true,
+		"default": true,
+	}
+	tests := []struct {
+		name            string
+		namespaceClient fakeNamespaceClient
+		want            []string
+		wantErr         string
+	}{
+		{
+			name: "subset of namespaces is accessible",
+			namespaceClient: fakeNamespaceClient{
+				namespaces: []string{"team-c", "team-b", "kube-system", "team-a"},
+			},
+			want: []string{"team-b"},
+		},
+		{
+			name: "no namespace is accessible",
+			namespaceClient: fakeNamespaceClient{
+				namespaces: []string{"kube-system"},
+			},
+			want: []string{},
+		},
+		{
+			name: "forbidden to list namespaces falls back to the remote namespace",
+			namespaceClient: fakeNamespaceClient{
+				err: apierrors.NewForbidden(corev1.Resource("namespaces"), "", errors.New("cluster scope")),
+			},
+			want: []string{"default"},
+		},
+		{
+			name: "list error",
+			namespaceClient: fakeNamespaceClient{
+				err: apierrors.NewServiceUnavailable("unavailable"),
+			},
+			wantErr: "unable to list namespaces: unavailable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ProviderKubernetes{
+				NamespaceClient: tt.namespaceClient,
+				AccessReviewClient: fakeNamespaceReviewClient{
+					t:       t,
+					granted: granted,
+				},
+				Namespace: "default",
+			}
+			got, err := p.ListAccessibleNamespaces(context.Background())
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
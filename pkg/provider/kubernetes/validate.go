@@ -15,25 +15,40 @@ package kubernetes
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
 	authv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 
 	esv1beta1 "github.com/external-secrets/external-secrets/apis/externalsecrets/v1beta1"
 	"github.com/external-secrets/external-secrets/pkg/utils"
 )
 
+// probeTimeout limits the TLS handshake of probeCABundle.
+const probeTimeout = 5 * time.Second
+
+var (
+	validationFailuresMu sync.Mutex
+	validationFailures   = map[string]int{}
+)
+
 func (p *ProviderKubernetes) ValidateStore(store esv1beta1.GenericStore) error {
 	storeSpec := store.GetSpec()
 	k8sSpec := storeSpec.Provider.Kubernetes
-	if k8sSpec.Server.CABundle == nil && k8sSpec.Server.CAProvider == nil {
-		return fmt.Errorf("a CABundle or CAProvider is required")
+	if err := validateServer(store, k8sSpec.Server); err != nil {
+		return err
 	}
-	if store.GetObjectKind().GroupVersionKind().Kind == esv1beta1.ClusterSecretStoreKind &&
-		k8sSpec.Server.CAProvider != nil &&
-		k8sSpec.Server.CAProvider.Namespace == nil {
-		return fmt.Errorf("CAProvider.namespace must not be empty with ClusterSecretStore")
+	if err := validateAuthMethods(k8sSpec.Auth); err != nil {
+		return err
 	}
 	if k8sSpec.Auth.Cert != nil {
 		if k8sSpec.Auth.Cert.ClientCert.Name == "" {
@@ -62,10 +77,145 @@ func (p *ProviderKubernetes) ValidateStore(store esv1beta1.GenericStore) error {
 			return err
 		}
 	}
+	return (&ProviderKubernetes{}).setTransforms(k8sSpec)
+}
+
+// validateServer checks the CA configuration and the cipher suites.
+func validateServer(store esv1beta1.GenericStore, server esv1beta1.KubernetesServer) error {
+	if server.CABundle == nil && server.CAProvider == nil {
+		return fmt.Errorf("a CABundle or CAProvider is required")
+	}
+	if store.GetObjectKind().GroupVersionKind().Kind == esv1beta1.ClusterSecretStoreKind &&
+		server.CAProvider != nil &&
+		server.CAProvider.Namespace == nil {
+		return fmt.Errorf("CAProvider.namespace must not be empty with ClusterSecretStore")
+	}
+	if _, err := cipherSuiteIDs(server.CipherSuites); err != nil {
+		return err
+	}
+	if server.ProbeCABundle && server.CABundle != nil {
+		return probeCABundle(server)
+	}
+	return nil
+}
+
+// probeCABundle performs a TLS handshake with every configured address of
+// the server and verifies its certificate with the CA bundle.
+func probeCABundle(server esv1beta1.KubernetesServer) error {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(server.CABundle) {
+		return fmt.Errorf("caBundle does not contain a PEM encoded certificate")
+	}
+	urls := server.URLs
+	if len(urls) == 0 && server.URL != "" {
+		urls = []string{server.URL}
+	}
+	if len(urls) == 0 {
+		return field.Required(field.NewPath("spec", "provider", "kubernetes", "server", "url"), "a server url is required to probe the caBundle")
+	}
+	for _, serverURL := range urls {
+		if err := probeServer(pool, server.TLSServerName, serverURL); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// probeServer performs a TLS handshake with a single server address.
+func probeServer(pool *x509.CertPool, serverName, serverURL string) error {
+	addr, host, err := serverAddress(serverURL)
+	if err != nil {
+		return err
+	}
+	if serverName == "" {
+		serverName = host
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	dialer := &tls.Dialer{
+		Config: &tls.Config{
+			RootCAs:    pool,
+			ServerName: serverName,
+			MinVersion: tls.VersionTLS12,
+		},
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("caBundle does not verify the certificate of %s: %w", addr, err)
+	}
+	return conn.Close()
+}
+
+// serverAddress returns the host:port and the host of a server URL.
+// The scheme defaults to https and the port to 443.
+func serverAddress(serverURL string) (string, string, error) {
+	if !strings.Contains(serverURL, "://") {
+		serverURL = "https://" + serverURL
+	}
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to parse server url: %w", err)
+	}
+	if u.Hostname() == "" {
+		return "", "", fmt.Errorf("server url %s has no host", serverURL)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+	return net.JoinHostPort(u.Hostname(), port), u.Hostname(), nil
+}
+
+// validateAuthMethods ensures that exactly one authentication method is configured.
+func validateAuthMethods(auth esv1beta1.KubernetesAuth) error {
+	path := field.NewPath("spec", "provider", "kubernetes", "auth")
+	methods := make([]string, 0, 1)
+	if auth.Cert != nil {
+		methods = append(methods, "auth.cert")
+	}
+	if auth.Token != nil {
+		methods = append(methods, "auth.token")
+	}
+	if auth.ServiceAccount != nil {
+		methods = append(methods, "auth.serviceAccount")
+	}
+	switch len(methods) {
+	case 0:
+		return field.Required(path, "exactly one of auth.cert, auth.token or auth.serviceAccount must be set")
+	case 1:
+		return nil
+	}
+	return field.Forbidden(path, fmt.Sprintf("exactly one of auth.cert, auth.token or auth.serviceAccount must be set, found: %s", strings.Join(methods, ", ")))
+}
+
 func (p *ProviderKubernetes) Validate() (esv1beta1.ValidationResult, error) {
+	result, err := p.validate()
+	return p.applyFailureThreshold(result, err)
+}
+
+// applyFailureThreshold counts the consecutive failed validations of the
+// store and keeps reporting it as ready until failureThreshold is reached.
+// A successful validation resets the count.
+func (p *ProviderKubernetes) applyFailureThreshold(result esv1beta1.ValidationResult, err error) (esv1beta1.ValidationResult, error) {
+	key := strings.Join([]string{p.storeKind, p.storeNamespace, p.storeName, p.Namespace}, "/")
+	validationFailuresMu.Lock()
+	defer validationFailuresMu.Unlock()
+	switch result {
+	case esv1beta1.ValidationResultReady:
+		delete(validationFailures, key)
+	case esv1beta1.ValidationResultError:
+		validationFailures[key]++
+		failures := validationFailures[key]
+		if p.store != nil && failures < p.store.FailureThreshold {
+			log.Info("store validation failed below failureThreshold", "store", p.storeName, "namespace", p.storeNamespace, "failures", failures, "error", err)
+			return esv1beta1.ValidationResultReady, nil
+		}
+	case esv1beta1.ValidationResultUnknown:
+	}
+	return result, err
+}
+
+func (p *ProviderKubernetes) validate() (esv1beta1.ValidationResult, error) {
 	// when using referent namespace we can not validate the token
 	// because the namespace is not known yet when Validate() is called
 	// from the SecretStore controller.
@@ -79,6 +229,10 @@ func (p *ProviderKubernetes) Validate() (esv1beta1.ValidationResult, error) {
 		},
 	}
 	authReview, err := p.ReviewClient.Create(ctx, &t, metav1.CreateOptions{})
+	// the store may still work without permission to create reviews
+	if apierrors.IsForbidden(err) {
+		return esv1beta1.ValidationResultUnknown, nil
+	}
 	if err != nil {
 		return esv1beta1.ValidationResultUnknown, fmt.Errorf("could not verify if client is valid: %w", err)
 	}
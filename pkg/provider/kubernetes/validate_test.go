@@ -15,12 +15,18 @@ package kubernetes
 
 import (
 	"context"
+	"encoding/pem"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	authv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/utils/pointer"
 
 	esv1beta1 "github.com/external-secrets/external-secrets/apis/externalsecrets/v1beta1"
@@ -29,15 +35,70 @@ import (
 
 type fakeReviewClient struct {
 	authReview *authv1.SelfSubjectRulesReview
+	err        error
 }
 
 func (fk fakeReviewClient) Create(ctx context.Context, selfSubjectAccessReview *authv1.SelfSubjectRulesReview, opts metav1.CreateOptions) (*authv1.SelfSubjectRulesReview, error) {
+	if fk.err != nil {
+		return nil, fk.err
+	}
 	if fk.authReview == nil {
 		return nil, errors.New(errSomethingWentWrong)
 	}
 	return fk.authReview, nil
 }
 
+func TestProbeCABundle(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	matching := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	_, mismatching := newTestCA(t, "other-ca")
+
+	err := validateServer(&esv1beta1.SecretStore{}, esv1beta1.KubernetesServer{
+		URL:           srv.URL,
+		CABundle:      matching,
+		ProbeCABundle: true,
+	})
+	assert.NoError(t, err)
+
+	err = validateServer(&esv1beta1.SecretStore{}, esv1beta1.KubernetesServer{
+		URL:           srv.URL,
+		CABundle:      mismatching,
+		ProbeCABundle: true,
+	})
+	assert.ErrorContains(t, err, "caBundle does not verify the certificate of")
+
+	// without the flag no connection is made
+	err = validateServer(&esv1beta1.SecretStore{}, esv1beta1.KubernetesServer{
+		URL:      srv.URL,
+		CABundle: mismatching,
+	})
+	assert.NoError(t, err)
+
+	// every address of urls is probed, the second one does not speak TLS
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer other.Close()
+	err = validateServer(&esv1beta1.SecretStore{}, esv1beta1.KubernetesServer{
+		URLs:          []string{srv.URL, other.URL},
+		CABundle:      matching,
+		ProbeCABundle: true,
+	})
+	assert.ErrorContains(t, err, "caBundle does not verify the certificate of "+other.Listener.Addr().String())
+
+	err = validateServer(&esv1beta1.SecretStore{}, esv1beta1.KubernetesServer{
+		CABundle:      matching,
+		ProbeCABundle: true,
+	})
+	assert.EqualError(t, err, "spec.provider.kubernetes.server.url: Required value: a server url is required to probe the caBundle")
+
+	err = validateServer(&esv1beta1.SecretStore{}, esv1beta1.KubernetesServer{
+		URL:           "https://",
+		CABundle:      matching,
+		ProbeCABundle: true,
+	})
+	assert.EqualError(t, err, "server url https:// has no host")
+}
+
 func TestValidateStore(t *testing.T) {
 	type fields struct {
 		Client       KClient
@@ -46,10 +107,11 @@ func TestValidateStore(t *testing.T) {
 	}
 
 	tests := []struct {
-		name    string
-		fields  fields
-		store   esv1beta1.GenericStore
-		wantErr bool
+		name       string
+		fields     fields
+		store      esv1beta1.GenericStore
+		wantErr    bool
+		wantErrMsg string
 	}{
 		{
 			name: "empty ca",
@@ -221,6 +283,155 @@ func TestValidateStore(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "no auth method",
+			store: &esv1beta1.SecretStore{
+				Spec: esv1beta1.SecretStoreSpec{
+					Provider: &esv1beta1.SecretStoreProvider{
+						Kubernetes: &esv1beta1.KubernetesProvider{
+							Server: esv1beta1.KubernetesServer{
+								CABundle: []byte("1234"),
+							},
+						},
+					},
+				},
+			},
+			wantErr:    true,
+			wantErrMsg: "spec.provider.kubernetes.auth: Required value: exactly one of auth.cert, auth.token or auth.serviceAccount must be set",
+		},
+		{
+			name: "multiple auth methods",
+			store: &esv1beta1.SecretStore{
+				Spec: esv1beta1.SecretStoreSpec{
+					Provider: &esv1beta1.SecretStoreProvider{
+						Kubernetes: &esv1beta1.KubernetesProvider{
+							Server: esv1beta1.KubernetesServer{
+								CABundle: []byte("1234"),
+							},
+							Auth: esv1beta1.KubernetesAuth{
+								Token: &esv1beta1.TokenAuth{
+									BearerToken: v1.SecretKeySelector{
+										Name: "foobar",
+										Key:  "token",
+									},
+								},
+								ServiceAccount: &v1.ServiceAccountSelector{
+									Name: "foobar",
+								},
+							},
+						},
+					},
+				},
+			},
+			wantErr:    true,
+			wantErrMsg: "spec.provider.kubernetes.auth: Forbidden: exactly one of auth.cert, auth.token or auth.serviceAccount must be set, found: auth.token, auth.serviceAccount",
+		},
+		{
+			name: "unknown cipher suite",
+			store: &esv1beta1.SecretStore{
+				Spec: esv1beta1.SecretStoreSpec{
+					Provider: &esv1beta1.SecretStoreProvider{
+						Kubernetes: &esv1beta1.KubernetesProvider{
+							Server: esv1beta1.KubernetesServer{
+								CABundle:     []byte("1234"),
+								CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384", "TLS_RSA_WITH_RC4_128_SHA"},
+							},
+							Auth: esv1beta1.KubernetesAuth{
+								ServiceAccount: &v1.ServiceAccountSelector{
+									Name: "foobar",
+								},
+							},
+						},
+					},
+				},
+			},
+			wantErr:    true,
+			wantErrMsg: "unknown or insecure cipher suite TLS_RSA_WITH_RC4_128_SHA",
+		},
+		{
+			name: "invalid value template",
+			store: &esv1beta1.SecretStore{
+				Spec: esv1beta1.SecretStoreSpec{
+					Provider: &esv1beta1.SecretStoreProvider{
+						Kubernetes: &esv1beta1.KubernetesProvider{
+							Server: esv1beta1.KubernetesServer{
+								CABundle: []byte("1234"),
+							},
+							Auth: esv1beta1.KubernetesAuth{
+								ServiceAccount: &v1.ServiceAccountSelector{
+									Name: "foobar",
+								},
+							},
+							ValueTemplate: "{{ .Value ",
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid exclude keys",
+			store: &esv1beta1.SecretStore{
+				Spec: esv1beta1.SecretStoreSpec{
+					Provider: &esv1beta1.SecretStoreProvider{
+						Kubernetes: &esv1beta1.KubernetesProvider{
+							Server: esv1beta1.KubernetesServer{
+								CABundle: []byte("1234"),
+							},
+							Auth: esv1beta1.KubernetesAuth{
+								ServiceAccount: &v1.ServiceAccountSelector{
+									Name: "foobar",
+								},
+							},
+							ExcludeKeys: "(",
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid denied keys",
+			store: &esv1beta1.SecretStore{
+				Spec: esv1beta1.SecretStoreSpec{
+					Provider: &esv1beta1.SecretStoreProvider{
+						Kubernetes: &esv1beta1.KubernetesProvider{
+							Server: esv1beta1.KubernetesServer{
+								CABundle: []byte("1234"),
+							},
+							Auth: esv1beta1.KubernetesAuth{
+								ServiceAccount: &v1.ServiceAccountSelector{
+									Name: "foobar",
+								},
+							},
+							DeniedKeys: "(",
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid require label",
+			store: &esv1beta1.SecretStore{
+				Spec: esv1beta1.SecretStoreSpec{
+					Provider: &esv1beta1.SecretStoreProvider{
+						Kubernetes: &esv1beta1.KubernetesProvider{
+							Server: esv1beta1.KubernetesServer{
+								CABundle: []byte("1234"),
+							},
+							Auth: esv1beta1.KubernetesAuth{
+								ServiceAccount: &v1.ServiceAccountSelector{
+									Name: "foobar",
+								},
+							},
+							RequireLabel: "eso.io/export in (",
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "valid auth",
 			store: &esv1beta1.SecretStore{
@@ -249,8 +460,13 @@ func TestValidateStore(t *testing.T) {
 				ReviewClient: tt.fields.ReviewClient,
 				Namespace:    tt.fields.Namespace,
 			}
-			if err := k.ValidateStore(tt.store); (err != nil) != tt.wantErr {
+			err := k.ValidateStore(tt.store)
+			if (err != nil) != tt.wantErr {
 				t.Errorf("ProviderKubernetes.ValidateStore() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErrMsg != "" && err.Error() != tt.wantErrMsg {
+				t.Errorf("ProviderKubernetes.ValidateStore() error = %v, want %v", err, tt.wantErrMsg)
 			}
 		})
 	}
@@ -316,6 +532,17 @@ func TestValidate(t *testing.T) {
 			want:    esv1beta1.ValidationResultUnknown,
 			wantErr: true,
 		},
+		{
+			name: "forbidden review results in unknown",
+			fields: fields{
+				Namespace: "default",
+				ReviewClient: fakeReviewClient{
+					err: apierrors.NewForbidden(schema.GroupResource{Group: "authorization.k8s.io", Resource: "selfsubjectrulesreviews"}, "", errors.New("forbidden")),
+				},
+			},
+			want:    esv1beta1.ValidationResultUnknown,
+			wantErr: false,
+		},
 		{
 			name: "not allowed results in error",
 			fields: fields{
@@ -355,3 +582,46 @@ func TestValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateFailureThreshold(t *testing.T) {
+	successReview := authv1.SelfSubjectRulesReview{
+		Status: authv1.SubjectRulesReviewStatus{
+			ResourceRules: []authv1.ResourceRule{
+				{
+					Verbs:     []string{"get"},
+					Resources: []string{"secrets"},
+				},
+			},
+		},
+	}
+	failReview := authv1.SelfSubjectRulesReview{}
+	newProvider := func(review *authv1.SelfSubjectRulesReview) *ProviderKubernetes {
+		return &ProviderKubernetes{
+			ReviewClient: fakeReviewClient{authReview: review},
+			Namespace:    "default",
+			storeName:    "failure-threshold",
+			store: &esv1beta1.KubernetesProvider{
+				FailureThreshold: 3,
+			},
+		}
+	}
+
+	// below the threshold the store stays ready
+	for i := 0; i < 2; i++ {
+		got, err := newProvider(&failReview).Validate()
+		assert.NoError(t, err)
+		assert.Equal(t, esv1beta1.ValidationResultReady, got)
+	}
+	// the third failure in a row marks it as not ready
+	got, err := newProvider(&failReview).Validate()
+	assert.EqualError(t, err, "client is not allowed to get secrets")
+	assert.Equal(t, esv1beta1.ValidationResultError, got)
+
+	// a success resets the count
+	got, err = newProvider(&successReview).Validate()
+	assert.NoError(t, err)
+	assert.Equal(t, esv1beta1.ValidationResultReady, got)
+	got, err = newProvider(&failReview).Validate()
+	assert.NoError(t, err)
+	assert.Equal(t, esv1beta1.ValidationResultReady, got)
+}
@@ -53,6 +53,12 @@ func ConvertKeys(strategy esv1beta1.ExternalSecretConversionStrategy, in map[str
 	return out, nil
 }
 
+// ConvertKey converts a single key into a valid key.
+// Replaces any non-alphanumeric characters depending on convert strategy.
+func ConvertKey(strategy esv1beta1.ExternalSecretConversionStrategy, key string) string {
+	return convert(strategy, key)
+}
+
 func convert(strategy esv1beta1.ExternalSecretConversionStrategy, str string) string {
 	rs := []rune(str)
 	newName := make([]string, len(rs))